@@ -0,0 +1,180 @@
+// Copyright 2025 Jelly Terra <jellyterra@proton.me>
+// This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0
+// that can be found in the LICENSE file and https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/autodns/autodns.go/core"
+)
+
+const (
+	ACMEChallengeLabel = "_acme-challenge"
+	ACMEChallengeTTL   = 60
+)
+
+// ReqACMEDNSUpdate is the body of acme-dns /update.
+// The subdomain is the name under validation, with or without the challenge label.
+type ReqACMEDNSUpdate struct {
+	Subdomain string `json:"subdomain"`
+	TXT       string `json:"txt"`
+}
+
+// ReqHTTPReq is the body of lego httpreq /present and /cleanup, in both default and raw mode.
+type ReqHTTPReq struct {
+	FQDN  string `json:"fqdn"`
+	Value string `json:"value"`
+
+	Domain  string `json:"domain"`
+	Token   string `json:"token"`
+	KeyAuth string `json:"keyAuth"`
+}
+
+// ChallengeName returns the challenge name of the name under validation.
+func ChallengeName(name string) string {
+	name = strings.TrimPrefix(strings.TrimSuffix(name, "."), "*.")
+	if !strings.HasPrefix(name, ACMEChallengeLabel+".") {
+		name = ACMEChallengeLabel + "." + name
+	}
+	return name
+}
+
+// ExecuteChallenge applies the TXT operation of each value on the challenge name, and returns once the registry has accepted them.
+// The records are not checked to be served by the authoritative nameservers.
+func ExecuteChallenge(c *core.Context, roleDef *core.RoleDef, observe func(err error, op *core.Operation), opName string, name string, values ...string) (int, error, error) {
+	name = ChallengeName(name)
+
+	domain, subdomain, err := core.SplitName(roleDef, name)
+	if err != nil {
+		return http.StatusForbidden, err, nil
+	}
+
+	var (
+		failed   []error
+		failedMu sync.Mutex
+	)
+
+	var operations []*core.Operation
+	for _, value := range values {
+		operations = append(operations, &core.Operation{
+			Record: core.Record{
+				Type:  "TXT",
+				Value: value,
+				TTL:   ACMEChallengeTTL,
+			},
+			Op:        opName,
+			Domain:    domain,
+			Subdomain: subdomain,
+		})
+	}
+
+	err = core.ExecuteAll(c, roleDef, operations, func(err error, op *core.Operation) {
+		observe(err, op)
+		if err != nil {
+			failedMu.Lock()
+			failed = append(failed, err)
+			failedMu.Unlock()
+		}
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(failed) != 0 {
+		return 0, nil, errors.Join(failed...)
+	}
+
+	return 0, nil, nil
+}
+
+// HandleACMEDNSUpdate serves acme-dns /update, authorized by X-Api-User as role and X-Api-Key as key.
+// As acme-dns, the challenge name keeps the latest two values, so validating a name and its wildcard together works.
+// The values are remembered in memory, so only the latest value is kept after restarts.
+func HandleACMEDNSUpdate(c *core.Context, opts *ServeOptions) http.HandlerFunc {
+	var (
+		latest     = map[string][]string{}
+		latestLock sync.Mutex
+	)
+
+	return HandleWrap(func(w http.ResponseWriter, r *http.Request) (int, error, error) {
+		role, token := r.Header.Get("X-Api-User"), r.Header.Get("X-Api-Key")
+		roleDef, code, err, iErr := Authorize(r, c, role, token)
+		if err != nil || iErr != nil {
+			return code, err, iErr
+		}
+
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			return 0, err, nil
+		}
+
+		req, err := UnmarshalJSON(b, &ReqACMEDNSUpdate{})
+		if err != nil {
+			return 0, err, nil
+		}
+		if req.Subdomain == "" || req.TXT == "" {
+			return 0, fmt.Errorf("requires [subdomain, txt]"), nil
+		}
+
+		name := ChallengeName(req.Subdomain)
+
+		latestLock.Lock()
+		defer latestLock.Unlock()
+
+		values := []string{req.TXT}
+		for _, prev := range latest[name] {
+			if prev != req.TXT {
+				values = append(values, prev)
+				break
+			}
+		}
+
+		code, err, iErr = ExecuteChallenge(c, roleDef, Observe(r, opts, role, token), core.OP_UPDATE, name, values...)
+		if err == nil && iErr == nil {
+			latest[name] = values
+		}
+		return code, err, iErr
+	})
+}
+
+// HandleHTTPReq serves lego httpreq /present and /cleanup, authorized by basic auth of role and key.
+func HandleHTTPReq(c *core.Context, opts *ServeOptions, opName string) http.HandlerFunc {
+	return HandleWrap(func(w http.ResponseWriter, r *http.Request) (int, error, error) {
+		role, token, _ := r.BasicAuth()
+		roleDef, code, err, iErr := Authorize(r, c, role, token)
+		if err != nil || iErr != nil {
+			return code, err, iErr
+		}
+
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			return 0, err, nil
+		}
+
+		req, err := UnmarshalJSON(b, &ReqHTTPReq{})
+		if err != nil {
+			return 0, err, nil
+		}
+
+		switch {
+		case req.FQDN != "" && req.Value != "":
+		case req.Domain != "" && req.KeyAuth != "":
+			// Raw mode sends the key authorization, the digest of which is the record value.
+			digest := sha256.Sum256([]byte(req.KeyAuth))
+			req.FQDN = req.Domain
+			req.Value = base64.RawURLEncoding.EncodeToString(digest[:])
+		default:
+			return 0, fmt.Errorf("requires [fqdn, value] or [domain, keyAuth]"), nil
+		}
+
+		return ExecuteChallenge(c, roleDef, Observe(r, opts, role, token), opName, req.FQDN, req.Value)
+	})
+}
@@ -0,0 +1,148 @@
+// Copyright 2025 Jelly Terra <jellyterra@proton.me>
+// This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0
+// that can be found in the LICENSE file and https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/autodns/autodns.go/core"
+)
+
+var ErrClientAddr = errors.New("client address unresolvable")
+
+// ClientAddrValue as the record value is replaced by the address the request came from.
+const ClientAddrValue = "@client"
+
+func ParsePrefixes(s string) (prefixes []netip.Prefix, _ error) {
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(field)
+		if err != nil {
+			addr, err := netip.ParseAddr(field)
+			if err != nil {
+				return nil, fmt.Errorf("parsing prefix [%s]: %v", field, err)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+func isTrusted(trusted []netip.Prefix, addr netip.Addr) bool {
+	return slices.ContainsFunc(trusted, func(prefix netip.Prefix) bool {
+		return prefix.Contains(addr)
+	})
+}
+
+// forwardedFor collects the addresses in the Forwarded header, or X-Forwarded-For when it is absent, from client to proxy.
+func forwardedFor(h http.Header) (addrs []string) {
+	if values := h.Values("Forwarded"); len(values) != 0 {
+		for _, value := range values {
+			for _, element := range strings.Split(value, ",") {
+				for _, pair := range strings.Split(element, ";") {
+					k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+					if !ok || !strings.EqualFold(k, "for") {
+						continue
+					}
+					v = strings.Trim(v, "\"")
+
+					// Bracketed IPv6 with an optional port, or IPv4 with an optional port.
+					if host, _, err := net.SplitHostPort(v); err == nil {
+						v = host
+					}
+					addrs = append(addrs, strings.Trim(v, "[]"))
+				}
+			}
+		}
+		return addrs
+	}
+
+	for _, value := range h.Values("X-Forwarded-For") {
+		for _, field := range strings.Split(value, ",") {
+			addrs = append(addrs, strings.TrimSpace(field))
+		}
+	}
+	return addrs
+}
+
+// ClientAddr resolves the address of the client.
+// Forwarding headers are believed only when the peer is a trusted proxy, and are walked back until the first untrusted hop.
+// A hop that is not an address, such as an obfuscated identifier, fails rather than resolving to a proxy.
+func ClientAddr(r *http.Request, trusted []netip.Prefix) (netip.Addr, error) {
+	addrPort, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	addr := addrPort.Addr().Unmap()
+
+	if !isTrusted(trusted, addr) {
+		return addr, nil
+	}
+
+	hops := forwardedFor(r.Header)
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(hops[i])
+		if err != nil {
+			return netip.Addr{}, fmt.Errorf("%w: forwarded hop [%s] is not an address", ErrClientAddr, hops[i])
+		}
+		addr = hop.Unmap()
+
+		if !isTrusted(trusted, addr) {
+			break
+		}
+	}
+
+	return addr, nil
+}
+
+// ResolveClientAddr replaces the value of operations referring to the client address.
+// The address is resolved only if any operation refers to it.
+func ResolveClientAddr(operations []*core.Operation, resolve func() (netip.Addr, error)) error {
+	var (
+		addr netip.Addr
+		typ  string
+	)
+
+	for _, op := range operations {
+		if op.Value != ClientAddrValue {
+			continue
+		}
+
+		if !addr.IsValid() {
+			var err error
+			addr, err = resolve()
+			if err != nil {
+				return err
+			}
+
+			typ = "A"
+			if addr.Is6() {
+				typ = "AAAA"
+			}
+		}
+
+		switch op.Type {
+		case "":
+			op.Type = typ
+		case typ:
+		default:
+			return fmt.Errorf("record type [%s] does not match client address [%s]", op.Type, addr)
+		}
+		op.Value = addr.String()
+	}
+
+	return nil
+}
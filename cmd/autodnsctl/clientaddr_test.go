@@ -0,0 +1,154 @@
+// Copyright 2025 Jelly Terra <jellyterra@proton.me>
+// This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0
+// that can be found in the LICENSE file and https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestClientAddr(t *testing.T) {
+	trusted, err := ParsePrefixes("10.0.0.0/8, 2001:db8:ffff::/48")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		title  string
+		remote string
+		header map[string][]string
+		want   string
+		// fail is whether resolving fails with ErrClientAddr.
+		fail bool
+	}{
+		{
+			title:  "no proxy",
+			remote: "203.0.113.7:50000",
+			want:   "203.0.113.7",
+		},
+		{
+			title:  "untrusted peer spoofing X-Forwarded-For",
+			remote: "203.0.113.7:50000",
+			header: map[string][]string{"X-Forwarded-For": {"198.51.100.1"}},
+			want:   "203.0.113.7",
+		},
+		{
+			title:  "untrusted peer spoofing Forwarded",
+			remote: "203.0.113.7:50000",
+			header: map[string][]string{"Forwarded": {"for=198.51.100.1"}},
+			want:   "203.0.113.7",
+		},
+		{
+			title:  "trusted peer without headers",
+			remote: "10.0.0.2:50000",
+			want:   "10.0.0.2",
+		},
+		{
+			title:  "IPv4-mapped trusted peer",
+			remote: "[::ffff:10.0.0.2]:50000",
+			header: map[string][]string{"X-Forwarded-For": {"198.51.100.1"}},
+			want:   "198.51.100.1",
+		},
+		{
+			title:  "chain of trusted hops",
+			remote: "10.0.0.3:50000",
+			header: map[string][]string{"X-Forwarded-For": {"198.51.100.1, 10.0.0.1", "10.0.0.2"}},
+			want:   "198.51.100.1",
+		},
+		{
+			title:  "spoofed hops left of the first untrusted hop",
+			remote: "10.0.0.3:50000",
+			header: map[string][]string{"X-Forwarded-For": {"192.0.2.66, 198.51.100.1, 10.0.0.1"}},
+			want:   "198.51.100.1",
+		},
+		{
+			title:  "all hops trusted",
+			remote: "10.0.0.3:50000",
+			header: map[string][]string{"X-Forwarded-For": {"10.0.0.1, 10.0.0.2"}},
+			want:   "10.0.0.1",
+		},
+		{
+			title:  "Forwarded over X-Forwarded-For",
+			remote: "10.0.0.3:50000",
+			header: map[string][]string{
+				"Forwarded":       {"for=198.51.100.1;proto=https, for=10.0.0.1"},
+				"X-Forwarded-For": {"192.0.2.66"},
+			},
+			want: "198.51.100.1",
+		},
+		{
+			title:  "bracketed IPv6 with port",
+			remote: "[2001:db8:ffff::1]:50000",
+			header: map[string][]string{"Forwarded": {`for="[2001:db8:cafe::17]:4711"`}},
+			want:   "2001:db8:cafe::17",
+		},
+		{
+			title:  "bracketed IPv6 without port",
+			remote: "10.0.0.3:50000",
+			header: map[string][]string{"Forwarded": {`for="[2001:db8:cafe::17]"`}},
+			want:   "2001:db8:cafe::17",
+		},
+		{
+			title:  "quoted IPv4 with port and case-insensitive key",
+			remote: "10.0.0.3:50000",
+			header: map[string][]string{"Forwarded": {`For="198.51.100.1:8080";by=10.0.0.3`}},
+			want:   "198.51.100.1",
+		},
+		{
+			title:  "obfuscated identifier",
+			remote: "10.0.0.3:50000",
+			header: map[string][]string{"Forwarded": {"for=_hidden, for=10.0.0.1"}},
+			fail:   true,
+		},
+		{
+			title:  "unknown identifier",
+			remote: "10.0.0.3:50000",
+			header: map[string][]string{"Forwarded": {"for=unknown"}},
+			fail:   true,
+		},
+		{
+			title:  "empty for",
+			remote: "10.0.0.3:50000",
+			header: map[string][]string{"Forwarded": {"for="}},
+			fail:   true,
+		},
+		{
+			title:  "malformed X-Forwarded-For",
+			remote: "10.0.0.3:50000",
+			header: map[string][]string{"X-Forwarded-For": {"198.51.100.1:80:80"}},
+			fail:   true,
+		},
+		{
+			title:  "pairs without for are ignored",
+			remote: "10.0.0.3:50000",
+			header: map[string][]string{"Forwarded": {"proto=https;host=jellyterra.com, garbage, for=198.51.100.1"}},
+			want:   "198.51.100.1",
+		},
+	} {
+		t.Run(tt.title, func(t *testing.T) {
+			r := &http.Request{RemoteAddr: tt.remote, Header: http.Header{}}
+			for k, values := range tt.header {
+				for _, v := range values {
+					r.Header.Add(k, v)
+				}
+			}
+
+			addr, err := ClientAddr(r, trusted)
+			if tt.fail {
+				if !errors.Is(err, ErrClientAddr) {
+					t.Errorf("ClientAddr() = %s, %v, want %v", addr, err, ErrClientAddr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if addr.String() != tt.want {
+				t.Errorf("ClientAddr() = %s, want %s", addr, tt.want)
+			}
+		})
+	}
+}
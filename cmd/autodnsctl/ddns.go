@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"github.com/autodns/autodns.go/core"
 	"io"
+	"log/slog"
 	"maps"
 	"net"
 	"net/http"
@@ -24,12 +25,13 @@ type Rule struct {
 	Pass bool   `json:"pass"`
 	Glob string `json:"glob"`
 
-	CompiledGlob *regexp.Regexp
+	CompiledGlob *regexp.Regexp `json:"-"`
 }
 
 type AddrSet struct {
 	Name       string   `json:"name"`
 	Interfaces []string `json:"interfaces"`
+	Echo       []string `json:"echo"`
 	Rules      []*Rule  `json:"rules"`
 }
 
@@ -48,6 +50,8 @@ type Zone struct {
 }
 
 type DDNSConfig struct {
+	Version int `json:"version,omitempty"`
+
 	AddrSets []AddrSet `json:"addr_sets"`
 	Zones    []Zone    `json:"zones"`
 }
@@ -75,13 +79,13 @@ func DDNS(configPath string) func() error {
 		}
 
 		if stat.ModTime().Unix() != lastModTime {
-			fmt.Println("Load configuration")
 			config, err = LoadDDNSConfig(configPath)
 			if err != nil {
 				return fmt.Errorf("loading config in JSON failed: %v", err)
 			}
 
 			lastModTime = stat.ModTime().Unix()
+			slog.Info("configuration loaded", "path", configPath)
 
 			initAddrSetsCache()
 		}
@@ -155,9 +159,11 @@ func DDNS(configPath string) func() error {
 			}
 
 			go func() {
+				logger := slog.With("server", zone.Server, "role", zone.Role)
+
 				u, err := url.JoinPath(zone.Server, "/v1/do")
 				if err != nil {
-					fmt.Println(err)
+					logger.Error("update failed", "error", err)
 					return
 				}
 
@@ -167,20 +173,27 @@ func DDNS(configPath string) func() error {
 					Operations: operations,
 				})))
 				if err != nil {
-					fmt.Println(err)
+					metricDDNSUpdates.Inc("failure")
+					logger.Error("update failed", "error", err)
+					return
 				}
+				defer resp.Body.Close()
+
 				if resp.StatusCode != http.StatusOK {
+					metricDDNSUpdates.Inc("failure")
 					b, err := io.ReadAll(resp.Body)
 					if err != nil {
-						fmt.Println(err)
+						logger.Error("update failed", "status", resp.StatusCode, "error", err)
 						return
 					}
-					fmt.Println(string(b))
+					logger.Error("update failed", "status", resp.StatusCode, "request_id", resp.Header.Get("X-Request-ID"), "response", string(b))
+					return
 				}
+				metricDDNSUpdates.Inc("success")
 			}()
 
 			for _, op := range operations {
-				fmt.Println("Update", op.CanonicalName, "=>", op.Value)
+				slog.Info("record update", "server", zone.Server, "name", op.CanonicalName, "type", op.Type, "value", op.Value)
 			}
 		}
 
@@ -194,10 +207,19 @@ func LoadDDNSConfig(path string) (*DDNSConfig, error) {
 		return nil, err
 	}
 
+	data, err = core.ToJSON(path, data)
+	if err != nil {
+		return nil, err
+	}
+
 	config, err := UnmarshalJSON(data, &DDNSConfig{})
 	if err != nil {
 		return nil, err
 	}
+	err = core.CheckVersion(config.Version)
+	if err != nil {
+		return nil, err
+	}
 
 	for _, addrSet := range config.AddrSets {
 		for _, rule := range addrSet.Rules {
@@ -227,9 +249,45 @@ func CollectAddrSet(addrSet AddrSet) ([]net.IP, error) {
 		return nil, err
 	}
 
+	addrs = append(addrs, CollectEcho(addrSet.Echo)...)
+
 	return FilterAddrsByRules(addrs, addrSet.Rules)
 }
 
+// CollectEcho asks the servers for the address they observe. Unreachable one will be ignored.
+func CollectEcho(servers []string) (collected []net.IP) {
+	client := http.Client{Timeout: 10 * time.Second}
+
+	for _, server := range servers {
+		u, err := url.JoinPath(server, "/v1/ip")
+		if err != nil {
+			slog.Warn("echo server unreachable", "server", server, "error", err)
+			continue
+		}
+
+		resp, err := client.Get(u)
+		if err != nil {
+			slog.Warn("echo server unreachable", "server", server, "error", err)
+			continue
+		}
+
+		ip, err := UnmarshalJSONFromReader(resp.Body, &RespIP{})
+		_ = resp.Body.Close()
+		if err != nil {
+			slog.Warn("echo server unreachable", "server", server, "error", err)
+			continue
+		}
+
+		addr := net.ParseIP(ip.IP)
+		if addr == nil {
+			slog.Warn("echo server returned invalid address", "server", server, "addr", ip.IP)
+			continue
+		}
+		collected = append(collected, addr)
+	}
+	return collected
+}
+
 func CollectInterfaces(interfaces []*net.Interface) (collected []net.IP, _ error) {
 	for _, iface := range interfaces {
 		addrs, err := iface.Addrs()
@@ -0,0 +1,172 @@
+// Copyright 2025 Jelly Terra <jellyterra@proton.me>
+// This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0
+// that can be found in the LICENSE file and https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/autodns/autodns.go/core"
+)
+
+const (
+	EVENT_APPLIED         = "applied"
+	EVENT_FAILED          = "failed"
+	EVENT_CONFIG_RELOADED = "config_reloaded"
+)
+
+// Event describes a change.
+type Event struct {
+	Type      string         `json:"type"`
+	Time      int64          `json:"time"`
+	Role      string         `json:"role,omitempty"`
+	RequestID string         `json:"request_id,omitempty"`
+	Registry  string         `json:"registry,omitempty"`
+	Op        string         `json:"op,omitempty"`
+	Name      string         `json:"name,omitempty"`
+	Records   []*core.Record `json:"records,omitempty"`
+	Error     string         `json:"error,omitempty"`
+
+	// Config is the config document that has been reloaded, as <kind>/<name>.
+	Config string `json:"config,omitempty"`
+}
+
+type subscriber struct {
+	C      chan *Event
+	filter func(event *Event) bool
+}
+
+// Broker fans out events to the subscribers. Slow subscribers miss events rather than block the publisher.
+type Broker struct {
+	subscribers map[*subscriber]struct{}
+	lock        sync.Mutex
+}
+
+func NewBroker() *Broker {
+	return &Broker{subscribers: map[*subscriber]struct{}{}}
+}
+
+func (b *Broker) Subscribe(filter func(event *Event) bool) *subscriber {
+	sub := &subscriber{C: make(chan *Event, 64), filter: filter}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.subscribers[sub] = struct{}{}
+
+	return sub
+}
+
+func (b *Broker) Unsubscribe(sub *subscriber) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	delete(b.subscribers, sub)
+}
+
+// Publish sends the event to the subscribers it passes the filter of.
+// The filters run outside the lock, as they may query the store.
+func (b *Broker) Publish(event *Event) {
+	b.lock.Lock()
+	subscribers := make([]*subscriber, 0, len(b.subscribers))
+	for sub := range b.subscribers {
+		subscribers = append(subscribers, sub)
+	}
+	b.lock.Unlock()
+
+	// Channels are never closed, so sending to a subscriber unsubscribed meanwhile is harmless.
+	for _, sub := range subscribers {
+		if !sub.filter(event) {
+			continue
+		}
+		select {
+		case sub.C <- event:
+		default:
+		}
+	}
+}
+
+// Visible tells whether the event can be seen by the role, by its delegations for changes or by itself for config.
+func Visible(c *core.Context, role string, event *Event) bool {
+	roleDef, err := core.Query(c, &core.RoleDef{}, "role", role)
+	if err != nil {
+		return false
+	}
+	if roleDef.Admin {
+		return true
+	}
+
+	switch event.Type {
+	case EVENT_CONFIG_RELOADED:
+		return event.Config == "role/"+role
+	default:
+		domain, subdomain, err := core.SplitName(roleDef, event.Name)
+		if err != nil {
+			return false
+		}
+		_, err = core.Validate(roleDef, domain, subdomain)
+		return err == nil
+	}
+}
+
+// HandleEvents streams the events visible to the role as Server-Sent Events.
+func HandleEvents(c *core.Context, broker *Broker) http.HandlerFunc {
+	return HandleWrap(func(w http.ResponseWriter, r *http.Request) (int, error, error) {
+		role, token, _ := r.BasicAuth()
+		_, code, err, iErr := Authorize(r, c, role, token)
+		if err != nil || iErr != nil {
+			return code, err, iErr
+		}
+
+		sub := broker.Subscribe(func(event *Event) bool {
+			return Visible(c, role, event)
+		})
+		defer broker.Unsubscribe(sub)
+
+		rc := http.NewResponseController(w)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		err = rc.Flush()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		keepAlive := time.NewTicker(30 * time.Second)
+		defer keepAlive.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return 0, nil, nil
+			case <-keepAlive.C:
+				_, err = fmt.Fprint(w, ": keep-alive\n\n")
+			case event := <-sub.C:
+				_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, MarshalJSON(event))
+			}
+			if err == nil {
+				err = rc.Flush()
+			}
+			if err != nil {
+				Logger(r).Debug("event stream closed", "error", err)
+				return 0, nil, nil
+			}
+		}
+	})
+}
+
+// PublishReload publishes reloads of config files.
+func PublishReload(broker *Broker) func(kind string, name string) {
+	return func(kind string, name string) {
+		slog.Info("config reloaded", "kind", kind, "name", name)
+		broker.Publish(&Event{
+			Type:   EVENT_CONFIG_RELOADED,
+			Time:   time.Now().Unix(),
+			Config: kind + "/" + name,
+		})
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2025 Jelly Terra <jellyterra@proton.me>
+// This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0
+// that can be found in the LICENSE file and https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/autodns/autodns.go/core"
+)
+
+type CheckStatus struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+func checkStatus(err error) *CheckStatus {
+	if err != nil {
+		return &CheckStatus{Error: err.Error()}
+	}
+	return &CheckStatus{OK: true}
+}
+
+type RespReady struct {
+	Ready      bool                    `json:"ready"`
+	CheckedAt  int64                   `json:"checked_at"`
+	ConfigDir  *CheckStatus            `json:"config_dir,omitempty"`
+	Registries map[string]*CheckStatus `json:"registries,omitempty"`
+}
+
+// Readiness checks the config dir and optionally every registry, caching the result for the lifetime.
+// Only callers with the token see the status of each check, which might reveal errors of providers.
+type Readiness struct {
+	C             *core.Context
+	Token         string
+	CheckRegistry bool
+	CacheLifetime int64
+	last          *RespReady
+	lastLock      sync.Mutex
+}
+
+func (rd *Readiness) check() *RespReady {
+	resp := &RespReady{
+		Ready:     true,
+		CheckedAt: time.Now().Unix(),
+	}
+
+	// Roles are listed as well, as they are read on every request.
+	_, err := core.List(rd.C, "role")
+	names, rErr := core.List(rd.C, "registry")
+	err = errors.Join(err, rErr)
+	resp.ConfigDir = checkStatus(err)
+	resp.Ready = err == nil
+
+	if !rd.CheckRegistry || err != nil {
+		return resp
+	}
+
+	var (
+		wg   sync.WaitGroup
+		lock sync.Mutex
+	)
+	resp.Registries = map[string]*CheckStatus{}
+
+	for _, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// Building authenticates against the provider.
+			registry, err := core.BuildRegistry(rd.C, name)
+			if err == nil {
+				err = registry.Close()
+			}
+
+			lock.Lock()
+			defer lock.Unlock()
+			resp.Registries[name] = checkStatus(err)
+			resp.Ready = resp.Ready && err == nil
+		}()
+	}
+	wg.Wait()
+
+	return resp
+}
+
+func (rd *Readiness) Result() *RespReady {
+	rd.lastLock.Lock()
+	defer rd.lastLock.Unlock()
+
+	if rd.last == nil || time.Now().Unix() >= rd.last.CheckedAt+rd.CacheLifetime {
+		rd.last = rd.check()
+	}
+	return rd.last
+}
+
+func (rd *Readiness) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	resp := rd.Result()
+	if !resp.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if !HasBearer(r, rd.Token) {
+		_, _ = w.Write(MarshalJSON(&RespReady{Ready: resp.Ready, CheckedAt: resp.CheckedAt}))
+		return
+	}
+	_, _ = w.Write(MarshalJSON(resp))
+}
+
+func HandleHealth(w http.ResponseWriter, r *http.Request) {
+	_, _ = w.Write([]byte(`{"ok":true}`))
+}
@@ -0,0 +1,133 @@
+// Copyright 2025 Jelly Terra <jellyterra@proton.me>
+// This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0
+// that can be found in the LICENSE file and https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/autodns/autodns.go/core"
+)
+
+func formatRecords(records []*core.Record) string {
+	values := make([]string, len(records))
+	for i, record := range records {
+		values[i] = record.Type + " " + record.Value
+	}
+	return "[" + strings.Join(values, ", ") + "]"
+}
+
+func _rollback(args []string) error {
+	f := flag.NewFlagSet("rollback", flag.ExitOnError)
+	var (
+		server    = f.String("server", "", "AutoDNS server URI prefix.")
+		adminRole = f.String("admin-role", "", "Role created with --admin.")
+		key       = f.String("key", "", "Key of the admin role, or its reference env:NAME, file:PATH, cred:NAME.")
+		name      = f.String("name", "", "Filter by canonical name.")
+		role      = f.String("role", "", "Filter by role.")
+		requestID = f.String("request-id", "", "Filter by request ID.")
+		since     = f.Int64("since", 0, "Filter by time in Unix epoch.")
+	)
+	_ = f.Parse(args)
+
+	filter := core.AuditFilter{
+		Name:      *name,
+		Role:      *role,
+		RequestID: *requestID,
+		Since:     *since,
+	}
+	if *server == "" || *adminRole == "" || *key == "" || filter.Empty() {
+		return fmt.Errorf("requires [server, admin-role, key] and one of [name, role, request-id, since]")
+	}
+
+	token, err := core.ResolveSecret(*key)
+	if err != nil {
+		return err
+	}
+
+	resp, err := post(&http.Client{Timeout: 10 * time.Minute}, *server, "/v1/admin/rollback", &ReqRollback{
+		Role:        *adminRole,
+		Token:       token,
+		AuditFilter: filter,
+	}, &RespRollback{})
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "REGISTRY\tNAME\tRESTORED\tRESULT")
+	failed := 0
+	for _, result := range resp.Restored {
+		status := "success"
+		if result.Error != "" {
+			status = "failure: " + result.Error
+			failed++
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", result.Registry, result.Name, formatRecords(result.Records), status)
+	}
+	err = w.Flush()
+	if err != nil {
+		return err
+	}
+
+	if failed != 0 {
+		return fmt.Errorf("%d names failed to restore", failed)
+	}
+	return nil
+}
+
+func _history(args []string) error {
+	f := flag.NewFlagSet("history", flag.ExitOnError)
+	var (
+		auditLog  = f.String("audit-log", "", "Path to audit log.")
+		name      = f.String("name", "", "Filter by canonical name.")
+		role      = f.String("role", "", "Filter by role.")
+		requestID = f.String("request-id", "", "Filter by request ID.")
+		since     = f.Int64("since", 0, "Filter by time in Unix epoch.")
+		asJSON    = f.Bool("json", false, "Print entries in JSONL.")
+	)
+	_ = f.Parse(args)
+
+	if *auditLog == "" {
+		return fmt.Errorf("requires [audit-log], optional [name, role, request-id, since]")
+	}
+
+	entries, err := core.ReadAuditLog(*auditLog)
+	if err != nil {
+		return err
+	}
+
+	entries = core.FilterAuditEntries(entries, &core.AuditFilter{
+		Name:      *name,
+		Role:      *role,
+		RequestID: *requestID,
+		Since:     *since,
+	})
+
+	if *asJSON {
+		for _, entry := range entries {
+			fmt.Println(string(MarshalJSON(entry)))
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "TIME\tREQUEST\tROLE\tOP\tNAME\tPRIOR\tNEW\tRESULT")
+	for _, entry := range entries {
+		result := entry.Result
+		if entry.Error != "" {
+			result += ": " + entry.Error
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			time.Unix(entry.Time, 0).UTC().Format(time.RFC3339), entry.RequestID, entry.Role, entry.Op, entry.Name,
+			formatRecords(entry.Prior), formatRecords(entry.New), result)
+	}
+	return w.Flush()
+}
@@ -6,4 +6,5 @@ package main
 
 import (
 	_ "github.com/autodns/autodns.go/registry/cloudflare"
+	_ "github.com/autodns/autodns.go/registry/mirror"
 )
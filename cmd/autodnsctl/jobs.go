@@ -0,0 +1,243 @@
+// Copyright 2025 Jelly Terra <jellyterra@proton.me>
+// This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0
+// that can be found in the LICENSE file and https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/autodns/autodns.go/core"
+)
+
+const (
+	JOB_QUEUED  = "queued"
+	JOB_RUNNING = "running"
+	JOB_DONE    = "done"
+	JOB_FAILED  = "failed"
+
+	JOB_OP_PENDING = "pending"
+	JOB_OP_SUCCESS = "success"
+	JOB_OP_FAILURE = "failure"
+)
+
+var (
+	ErrJobQueueFull = errors.New("job queue is full")
+	ErrJobsStopped  = errors.New("jobs stopped")
+)
+
+type JobOperation struct {
+	Op     string `json:"op"`
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Value  string `json:"value"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type Job struct {
+	ID         string          `json:"id"`
+	Role       string          `json:"role"`
+	Status     string          `json:"status"`
+	Created    int64           `json:"created"`
+	Finished   int64           `json:"finished,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	Done       int             `json:"done"`
+	Operations []*JobOperation `json:"operations"`
+
+	run  func()
+	lock sync.Mutex
+}
+
+// fail marks the job and its pending operations failed, as it will not be run.
+func (job *Job) fail(err error) {
+	job.lock.Lock()
+	defer job.lock.Unlock()
+
+	for _, op := range job.Operations {
+		if op.Status == JOB_OP_PENDING {
+			op.Status = JOB_OP_FAILURE
+			op.Error = err.Error()
+			job.Done++
+		}
+	}
+	job.Status = JOB_FAILED
+	job.Error = err.Error()
+	job.Finished = time.Now().Unix()
+}
+
+// Snapshot copies the job for reading while it is in progress.
+func (job *Job) Snapshot() *Job {
+	job.lock.Lock()
+	defer job.lock.Unlock()
+
+	snapshot := &Job{
+		ID:       job.ID,
+		Role:     job.Role,
+		Status:   job.Status,
+		Created:  job.Created,
+		Finished: job.Finished,
+		Error:    job.Error,
+		Done:     job.Done,
+	}
+	for _, op := range job.Operations {
+		copied := *op
+		snapshot.Operations = append(snapshot.Operations, &copied)
+	}
+	return snapshot
+}
+
+// Jobs executes operations in background by a bounded pool of workers, and keeps finished jobs for the lifetime.
+// Jobs still queued when the context is done are marked failed.
+type Jobs struct {
+	Lifetime int64
+
+	ctx      context.Context
+	queue    chan *Job
+	jobs     map[string]*Job
+	jobsLock sync.Mutex
+}
+
+func NewJobs(ctx context.Context, workers int, queueSize int, lifetime int64) *Jobs {
+	j := &Jobs{
+		Lifetime: lifetime,
+		ctx:      ctx,
+		queue:    make(chan *Job, queueSize),
+		jobs:     map[string]*Job{},
+	}
+
+	for range workers {
+		go func() {
+			for {
+				select {
+				case job := <-j.queue:
+					if ctx.Err() != nil {
+						job.fail(ErrJobsStopped)
+						continue
+					}
+					job.run()
+				case <-ctx.Done():
+					j.drain()
+					return
+				}
+			}
+		}()
+	}
+
+	return j
+}
+
+// drain fails the jobs left in the queue. Submit is locked out, so no job is queued after it.
+func (j *Jobs) drain() {
+	j.jobsLock.Lock()
+	defer j.jobsLock.Unlock()
+
+	for {
+		select {
+		case job := <-j.queue:
+			job.fail(ErrJobsStopped)
+		default:
+			return
+		}
+	}
+}
+
+func (j *Jobs) purge() {
+	now := time.Now().Unix()
+	for id, job := range j.jobs {
+		job.lock.Lock()
+		expired := job.Finished != 0 && now > job.Finished+j.Lifetime
+		job.lock.Unlock()
+
+		if expired {
+			delete(j.jobs, id)
+		}
+	}
+}
+
+// Submit queues the operations, executed by the function with the callback tracking the progress.
+func (j *Jobs) Submit(role string, operations []*core.Operation, execute func(callback func(err error, op *core.Operation)) error) (*Job, error) {
+	job := &Job{
+		ID:      NewRequestID(),
+		Role:    role,
+		Status:  JOB_QUEUED,
+		Created: time.Now().Unix(),
+	}
+
+	index := map[*core.Operation]*JobOperation{}
+	for _, op := range operations {
+		jobOp := &JobOperation{
+			Op:     op.Op,
+			Type:   op.Type,
+			Value:  op.Value,
+			Status: JOB_OP_PENDING,
+		}
+		job.Operations = append(job.Operations, jobOp)
+		index[op] = jobOp
+	}
+
+	job.run = func() {
+		job.lock.Lock()
+		job.Status = JOB_RUNNING
+		job.lock.Unlock()
+
+		err := execute(func(err error, op *core.Operation) {
+			job.lock.Lock()
+			defer job.lock.Unlock()
+
+			jobOp, exist := index[op]
+			if !exist {
+				return
+			}
+			if jobOp.Status == JOB_OP_PENDING {
+				job.Done++
+			}
+
+			jobOp.Name = op.CanonicalName
+			if err != nil {
+				jobOp.Status = JOB_OP_FAILURE
+				jobOp.Error = err.Error()
+			} else if jobOp.Status == JOB_OP_PENDING {
+				jobOp.Status = JOB_OP_SUCCESS
+			}
+		})
+
+		job.lock.Lock()
+		defer job.lock.Unlock()
+
+		job.Status = JOB_DONE
+		if err != nil {
+			job.Status = JOB_FAILED
+			job.Error = err.Error()
+		}
+		job.Finished = time.Now().Unix()
+	}
+
+	j.jobsLock.Lock()
+	defer j.jobsLock.Unlock()
+	j.purge()
+
+	if j.ctx.Err() != nil {
+		return nil, ErrJobsStopped
+	}
+
+	select {
+	case j.queue <- job:
+	default:
+		return nil, ErrJobQueueFull
+	}
+	j.jobs[job.ID] = job
+
+	return job, nil
+}
+
+func (j *Jobs) Get(id string) *Job {
+	j.jobsLock.Lock()
+	defer j.jobsLock.Unlock()
+	j.purge()
+
+	return j.jobs[id]
+}
@@ -7,7 +7,6 @@ package main
 import (
 	"encoding/json"
 	"io"
-	"os"
 )
 
 func MarshalJSON[T any](v T) []byte {
@@ -21,16 +20,6 @@ func MarshalJSONToWriter(w io.Writer, v any) error {
 	return err
 }
 
-func MarshalJSONToPath(path string, v any) error {
-	f, err := os.Create(path)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
-	return MarshalJSONToWriter(f, v)
-}
-
 func UnmarshalJSON[T any](data []byte, v *T) (*T, error) {
 	return v, json.Unmarshal(data, v)
 }
@@ -42,11 +31,3 @@ func UnmarshalJSONFromReader[T any](r io.Reader, v *T) (*T, error) {
 	}
 	return UnmarshalJSON[T](data, v)
 }
-
-func UnmarshalJSONFromPath[T any](path string, v *T) (*T, error) {
-	b, err := os.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
-	return UnmarshalJSON[T](b, v)
-}
@@ -0,0 +1,73 @@
+// Copyright 2025 Jelly Terra <jellyterra@proton.me>
+// This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0
+// that can be found in the LICENSE file and https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/autodns/autodns.go/core"
+)
+
+// RedactedKeys are the attribute keys whose values are never logged.
+var RedactedKeys = []string{"token", "key", "api_token", "password", "secret"}
+
+func redact(_ []string, a slog.Attr) slog.Attr {
+	if slices.Contains(RedactedKeys, strings.ToLower(a.Key)) {
+		return slog.String(a.Key, "[REDACTED]")
+	}
+	return a
+}
+
+// SetupLogger sets the default logger by level (debug, info, warn, error) and format (text, json).
+func SetupLogger(level string, format string) error {
+	var lvl slog.Level
+	err := lvl.UnmarshalText([]byte(level))
+	if err != nil {
+		return fmt.Errorf("parsing log level [%s]: %v", level, err)
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl, ReplaceAttr: redact}
+
+	var handler slog.Handler
+	switch format {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("unknown log format [%s]", format)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+func NewRequestID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Logger returns the logger of the request.
+func Logger(r *http.Request) *slog.Logger {
+	return slog.With("request_id", RequestInfo(r).ID)
+}
+
+// LogOperation logs the result of the operation.
+func LogOperation(logger *slog.Logger, err error, op *core.Operation) {
+	attrs := []any{"op", op.Op, "name", op.CanonicalName, "type", op.Type, "value", op.Value, "ttl", op.TTL}
+	if err != nil {
+		logger.Error("operation failed", append(attrs, "error", err)...)
+		return
+	}
+	logger.Info("operation applied", attrs...)
+}
@@ -9,11 +9,15 @@ import (
 	"flag"
 	"fmt"
 	"github.com/autodns/autodns.go/core"
+	"github.com/autodns/autodns.go/metrics"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"path"
 	"regexp"
+	"strings"
 	"syscall"
 	"time"
 )
@@ -39,6 +43,14 @@ func _main() error {
 		fmt.Println("\tserve           Run as server.")
 		fmt.Println("\tddns            Run as DDNS client.")
 		fmt.Println("\tserver-config   Configure.")
+		fmt.Println("\thistory         Show changes in audit log.")
+		fmt.Println("\trollback        Roll back changes in audit log through server.")
+		fmt.Println("\tvalidate        Validate config.")
+		fmt.Println("\tplan            Show changes to apply a manifest.")
+		fmt.Println("\tapply           Apply a manifest.")
+		fmt.Println("\texport          Export records of a registry as zone file.")
+		fmt.Println("\timport          Import records of zone file into a registry.")
+		fmt.Println("\tmigrate         Replicate records from a registry into another.")
 		fmt.Println("Learn more via subcommand with option --help")
 	}
 	flag.Parse()
@@ -55,6 +67,22 @@ func _main() error {
 		return _ddns(os.Args[2:])
 	case "server-config":
 		return _server_config(os.Args[2:])
+	case "history":
+		return _history(os.Args[2:])
+	case "rollback":
+		return _rollback(os.Args[2:])
+	case "validate":
+		return _validate(os.Args[2:])
+	case "plan":
+		return _plan(os.Args[2:])
+	case "apply":
+		return _apply(os.Args[2:])
+	case "export":
+		return _export(os.Args[2:])
+	case "import":
+		return _import(os.Args[2:])
+	case "migrate":
+		return _migrate(os.Args[2:])
 	default:
 		return fmt.Errorf("unknown subcommand %s", os.Args[1])
 	}
@@ -64,13 +92,72 @@ func _serve(args []string) error {
 	f := flag.NewFlagSet("serve", flag.ExitOnError)
 	var (
 		baseDir   = f.String("config-dir", ".", "Base directory for reading config in JSON.")
+		storeType = f.String("store", "fs", "Config store: fs, bolt.")
+		masterKey = f.String("master-key", "", "Master key in base64 of 32 bytes to encrypt config at rest, or its reference env:NAME, file:PATH, cred:NAME. Empty to be disabled.")
 		httpAddr  = f.String("http-addr", ":5380", "HTTP listen address.")
 		httpRoute = f.String("http-route", "/", "HTTP route.")
 
+		trustedProxies = f.String("trusted-proxies", "", "Comma-separated CIDRs of reverse proxies whose X-Forwarded-For and Forwarded headers are trusted.")
+
 		cacheLifetime = f.Int64("cache-lifetime", 3600, "Cache lifetime in seconds.")
+
+		maxOperations             = f.Int("max-operations", 1000, "Operations in a request at most. Zero to be unlimited.")
+		maxConcurrency            = f.Int("max-concurrency", 16, "Concurrent registry calls of a request at most. Zero to be unlimited.")
+		maxConcurrencyPerRegistry = f.Int("max-registry-concurrency", 8, "Concurrent calls to a registry across requests at most. Zero to be unlimited.")
+
+		auditLog           = f.String("audit-log", "", "Path to append-only audit log in JSONL. Empty to be disabled.")
+		auditLogMaxSize    = f.Int64("audit-log-max-size", 100, "Audit log size in MiB to rotate.")
+		auditLogMaxBackups = f.Int("audit-log-max-backups", 10, "Rotated audit logs to keep.")
+
+		webhookRetries    = f.Int("webhook-retries", 5, "Retries of webhook delivery.")
+		webhookDeadLetter = f.String("webhook-dead-letter", "", "Path to JSONL file of undeliverable webhook events. Empty to be logged only.")
+		webhookWorkers    = f.Int("webhook-workers", 4, "Workers delivering webhook events.")
+		webhookQueue      = f.Int("webhook-queue", 1000, "Webhook events waiting for workers at most, beyond which they are dropped.")
+
+		jobWorkers  = f.Int("job-workers", 4, "Workers executing asynchronous jobs.")
+		jobQueue    = f.Int("job-queue", 64, "Asynchronous jobs waiting for workers at most.")
+		jobLifetime = f.Int64("job-lifetime", 3600, "Finished job lifetime in seconds.")
+
+		monitoringToken = f.String("monitoring-token", "", "Bearer token for /metrics and the details of /readyz, or its reference env:NAME, file:PATH, cred:NAME. Empty to disable /metrics.")
+
+		readyCheckRegistries = f.Bool("ready-check-registries", false, "Build and authenticate every registry on readiness check.")
+		readyCacheLifetime   = f.Int64("ready-cache-lifetime", 30, "Readiness result cache lifetime in seconds.")
+
+		logLevel  = f.String("log-level", "info", "Log level: debug, info, warn, error.")
+		logFormat = f.String("log-format", "text", "Log format: text, json.")
 	)
 	_ = f.Parse(args)
 
+	err := SetupLogger(*logLevel, *logFormat)
+	if err != nil {
+		return err
+	}
+
+	trusted, err := ParsePrefixes(*trustedProxies)
+	if err != nil {
+		return err
+	}
+
+	*monitoringToken, err = core.ResolveSecret(*monitoringToken)
+	if err != nil {
+		return err
+	}
+
+	store, err := OpenStore(*storeType, *baseDir, *masterKey)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	var audit *core.AuditLog
+	if *auditLog != "" {
+		audit, err = core.OpenAuditLog(*auditLog, *auditLogMaxSize<<20, *auditLogMaxBackups)
+		if err != nil {
+			return err
+		}
+		defer audit.Close()
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -79,13 +166,48 @@ func _serve(args []string) error {
 		cancel()
 	}()
 
-	fmt.Println("Listen and serve on http://" + path.Join(*httpAddr, *httpRoute) + "/")
+	slog.Info("listen and serve", "url", "http://"+path.Join(*httpAddr, *httpRoute)+"/")
 
-	return Serve(ctx, &core.Context{
-		BaseDir:       *baseDir,
+	c := &core.Context{
+		Store:         store,
 		CacheLifetime: *cacheLifetime,
 		Cache:         map[string]*core.ContextCache{},
-	}, *httpAddr, *httpRoute)
+
+		CollectPrior: audit != nil,
+
+		MaxOperations:             *maxOperations,
+		MaxConcurrency:            *maxConcurrency,
+		MaxConcurrencyPerRegistry: *maxConcurrencyPerRegistry,
+	}
+
+	webhooks := NewWebhooks(ctx, c, *webhookWorkers, *webhookQueue, *webhookRetries, *webhookDeadLetter)
+	defer webhooks.Wait()
+
+	events := NewBroker()
+	c.OnReload = PublishReload(events)
+
+	notified, err := c.Watch(ctx)
+	if err != nil {
+		slog.Warn("watching config store failed, checking config on each lookup", "error", err)
+	} else {
+		slog.Info("watching config store", "notified", notified)
+	}
+
+	return Serve(ctx, c, &ServeOptions{
+		Addr:           *httpAddr,
+		Route:          *httpRoute,
+		TrustedProxies: trusted,
+
+		MonitoringToken: *monitoringToken,
+
+		ReadyCheckRegistries: *readyCheckRegistries,
+		ReadyCacheLifetime:   *readyCacheLifetime,
+
+		Audit:    audit,
+		Webhooks: webhooks,
+		Events:   events,
+		Jobs:     NewJobs(ctx, *jobWorkers, *jobQueue, *jobLifetime),
+	})
 }
 
 func _ddns(args []string) error {
@@ -93,9 +215,28 @@ func _ddns(args []string) error {
 	var (
 		configPath      = f.String("config", "./ddns.json", "Path to DDNS config file.")
 		triggerDuration = f.Int("trigger-duration", 30, "Duration to trigger DNS update. Duration in seconds.")
+		echoInterval    = f.Int("echo-interval", 300, "Interval to trigger DNS update for addresses observed by echo servers, which change without notification. Duration in seconds. Zero to be disabled.")
+		metricsAddr     = f.String("metrics-addr", "", "HTTP listen address for Prometheus metrics. Empty to be disabled.")
+
+		logLevel  = f.String("log-level", "info", "Log level: debug, info, warn, error.")
+		logFormat = f.String("log-format", "text", "Log format: text, json.")
 	)
 	_ = f.Parse(args)
 
+	err := SetupLogger(*logLevel, *logFormat)
+	if err != nil {
+		return err
+	}
+
+	if *metricsAddr != "" {
+		go func() {
+			err := http.ListenAndServe(*metricsAddr, metrics.Handler())
+			if err != nil {
+				log.Fatalln("metrics listener failed:", err)
+			}
+		}()
+	}
+
 	triggerC := make(chan struct{}, 1)
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -108,6 +249,12 @@ func _ddns(args []string) error {
 		}
 	}()
 
+	if *echoInterval > 0 {
+		go func() {
+			_ = TimerNotify(ctx, *echoInterval, triggerC)
+		}()
+	}
+
 	triggerDDNS := DDNS(*configPath)
 
 	triggerC <- struct{}{}
@@ -115,6 +262,8 @@ func _ddns(args []string) error {
 	for {
 		select {
 		case <-triggerC:
+			metricDDNSTriggers.Inc()
+
 			delay := time.After(1 * time.Second)
 			func() {
 				for {
@@ -139,7 +288,15 @@ func _ddns(args []string) error {
 func _server_config(args []string) error {
 	f := flag.NewFlagSet("server-config", flag.ExitOnError)
 	var (
-		baseDir = f.String("config-dir", ".", "Base directory for storing config in JSON.")
+		baseDir   = f.String("config-dir", ".", "Base directory for storing config in JSON.")
+		storeType = f.String("store", "fs", "Config store: fs, bolt.")
+		copyTo    = f.String("copy-to", "", "Copy all config into the store of the type: fs, bolt.")
+
+		masterKey         = f.String("master-key", "", "Master key in base64 of 32 bytes to encrypt config at rest, or its reference env:NAME, file:PATH, cred:NAME. Empty to be disabled.")
+		newMasterKey      = f.String("new-master-key", "", "New master key to rotate to, in the same form as --master-key.")
+		generateMasterKey = f.Bool("generate-master-key", false, "Print a new master key.")
+		encryptAll        = f.Bool("encrypt-all", false, "Encrypt all config by the master key.")
+		rotateMasterKey   = f.Bool("rotate-master-key", false, "Re-encrypt all config from the master key to the new master key.")
 
 		role     = f.String("role", "", "Role name.")
 		key      = f.String("key", "", "Key name.")
@@ -148,6 +305,7 @@ func _server_config(args []string) error {
 		glob     = f.String("glob", "", "Glob pattern. Empty to be **the same only**.")
 		registry = f.String("registry", "", "Registry name.")
 
+		admin      = f.Bool("admin", false, "Allow the created role to use administrative APIs.")
 		createRole = f.Bool("create-role", false, "Create role.")
 		deleteRole = f.Bool("delete-role", false, "Delete role.")
 		createKey  = f.Bool("create-key", false, "Create key.")
@@ -164,13 +322,42 @@ func _server_config(args []string) error {
 
 		createRegistry = f.Bool("create-registry", false, "Create registry.")
 		deleteRegistry = f.Bool("delete-registry", false, "Delete registry.")
+
+		webhook           = f.String("webhook", "", "Webhook name.")
+		webhookURL        = f.String("webhook-url", "", "Webhook URL.")
+		webhookSecret     = f.String("webhook-secret", "", "Webhook secret for signing payloads, or its reference env:NAME, file:PATH, cred:NAME.")
+		webhookRoles      = f.String("webhook-roles", "", "Comma-separated roles whose changes are sent. Empty to be all.")
+		webhookRegistries = f.String("webhook-registries", "", "Comma-separated registries whose changes are sent. Empty to be all.")
+
+		createWebhook = f.Bool("create-webhook", false, "Create webhook.")
+		deleteWebhook = f.Bool("delete-webhook", false, "Delete webhook.")
 	)
 	_ = f.Parse(args)
 
-	var (
-		rolePath     = path.Join(*baseDir, "role", *role+".json")
-		registryPath = path.Join(*baseDir, "registry", *registry+".json")
-	)
+	if *generateMasterKey {
+		fmt.Println(core.GenerateMasterKey())
+		return nil
+	}
+
+	store, err := OpenStore(*storeType, *baseDir, *masterKey)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	// Edits by concurrent runs would be lost otherwise.
+	unlock, err := store.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	splitList := func(s string) []string {
+		if s == "" {
+			return nil
+		}
+		return strings.Split(s, ",")
+	}
 
 	switch {
 	case *createRole:
@@ -178,9 +365,11 @@ func _server_config(args []string) error {
 			return fmt.Errorf("requires [role]")
 		}
 
-		err := MarshalJSONToPath(rolePath, &core.RoleDef{
+		err := core.Save(store, "role", *role, &core.RoleDef{
+			Version:        core.CONFIG_VERSION,
 			Keys:           map[string]core.AuthKeyDef{},
 			ManagedDomains: map[string]core.ManagedDomainDef{},
+			Admin:          *admin,
 		})
 		if err != nil {
 			return err
@@ -192,7 +381,7 @@ func _server_config(args []string) error {
 			return fmt.Errorf("requires [role]")
 		}
 
-		err := os.Remove(rolePath)
+		err := store.Delete("role", *role)
 		if err != nil {
 			return err
 		}
@@ -203,7 +392,7 @@ func _server_config(args []string) error {
 			return fmt.Errorf("requires [role, key], optional [expire-at]")
 		}
 
-		roleDef, err := UnmarshalJSONFromPath(rolePath, &core.RoleDef{})
+		roleDef, err := core.Load(store, &core.RoleDef{}, "role", *role)
 		if err != nil {
 			return err
 		}
@@ -212,7 +401,7 @@ func _server_config(args []string) error {
 			Expire: *expireAt,
 		}
 
-		err = MarshalJSONToPath(rolePath, &roleDef)
+		err = core.Save(store, "role", *role, roleDef)
 		if err != nil {
 			return err
 		}
@@ -223,14 +412,14 @@ func _server_config(args []string) error {
 			return fmt.Errorf("requires [role, key]")
 		}
 
-		roleDef, err := UnmarshalJSONFromPath(rolePath, &core.RoleDef{})
+		roleDef, err := core.Load(store, &core.RoleDef{}, "role", *role)
 		if err != nil {
 			return err
 		}
 
 		delete(roleDef.Keys, *key)
 
-		err = MarshalJSONToPath(rolePath, &roleDef)
+		err = core.Save(store, "role", *role, roleDef)
 		if err != nil {
 			return err
 		}
@@ -241,7 +430,7 @@ func _server_config(args []string) error {
 			return fmt.Errorf("requires [role, domain, registry], optional [glob]")
 		}
 
-		roleDef, err := UnmarshalJSONFromPath(rolePath, &core.RoleDef{})
+		roleDef, err := core.Load(store, &core.RoleDef{}, "role", *role)
 		if err != nil {
 			return err
 		}
@@ -261,7 +450,7 @@ func _server_config(args []string) error {
 			Glob:     *glob,
 		}
 
-		err = MarshalJSONToPath(rolePath, &roleDef)
+		err = core.Save(store, "role", *role, roleDef)
 		if err != nil {
 			return err
 		}
@@ -272,14 +461,14 @@ func _server_config(args []string) error {
 			return fmt.Errorf("requires [role, domain]")
 		}
 
-		roleDef, err := UnmarshalJSONFromPath(rolePath, &core.RoleDef{})
+		roleDef, err := core.Load(store, &core.RoleDef{}, "role", *role)
 		if err != nil {
 			return err
 		}
 
 		delete(roleDef.ManagedDomains, *domain)
 
-		err = MarshalJSONToPath(rolePath, &roleDef)
+		err = core.Save(store, "role", *role, roleDef)
 		if err != nil {
 			return err
 		}
@@ -290,11 +479,12 @@ func _server_config(args []string) error {
 			return fmt.Errorf("requires [registry, builder]")
 		}
 
-		if core.RegistryBuilders[*builder] == nil {
+		if !core.IsBuiltin(*builder) {
 			fmt.Printf("Warning: registry builder [%s] is not builtin and not available!\n", *builder)
 		}
 
-		err := MarshalJSONToPath(path.Join(*baseDir, "registry", *registry+".json"), &core.RegistryDef{
+		err := core.Save(store, "registry", *registry, &core.RegistryDef{
+			Version:       core.CONFIG_VERSION,
 			Builder:       *builder,
 			BuilderParams: map[string]string{},
 		})
@@ -304,7 +494,7 @@ func _server_config(args []string) error {
 
 		fmt.Printf("Registry [%s] using builder [%s] created.\n", *registry, *builder)
 	case *deleteRegistry:
-		err := os.Remove(registryPath)
+		err := store.Delete("registry", *registry)
 		if err != nil {
 			return err
 		}
@@ -315,19 +505,89 @@ func _server_config(args []string) error {
 			return fmt.Errorf("requires [registry, builder-param-key, builder-param-val]")
 		}
 
-		registryDef, err := UnmarshalJSONFromPath(registryPath, &core.RegistryDef{})
+		registryDef, err := core.Load(store, &core.RegistryDef{}, "registry", *registry)
 		if err != nil {
 			return err
 		}
 
 		registryDef.BuilderParams[*builderParamKey] = *builderParamVal
 
-		err = MarshalJSONToPath(registryPath, &registryDef)
+		err = core.Save(store, "registry", *registry, registryDef)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Registry [%s] builder param [%s] has been set to [%s].\n", *registry, *builderParamKey, core.MaskParam(*builderParamKey, *builderParamVal))
+	case *createWebhook:
+		if *webhook == "" || *webhookURL == "" {
+			return fmt.Errorf("requires [webhook, webhook-url], optional [webhook-secret, webhook-roles, webhook-registries]")
+		}
+
+		err := core.Save(store, "webhook", *webhook, &core.WebhookDef{
+			Version:    core.CONFIG_VERSION,
+			URL:        *webhookURL,
+			Secret:     *webhookSecret,
+			Roles:      splitList(*webhookRoles),
+			Registries: splitList(*webhookRegistries),
+		})
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Webhook [%s] to [%s] created.\n", *webhook, *webhookURL)
+	case *deleteWebhook:
+		if *webhook == "" {
+			return fmt.Errorf("requires [webhook]")
+		}
+
+		err := store.Delete("webhook", *webhook)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Webhook [%s] removed.\n", *webhook)
+	case *encryptAll:
+		encrypted, ok := store.(*core.EncryptedStore)
+		if !ok {
+			return fmt.Errorf("requires [master-key]")
+		}
+
+		n, err := core.Reencrypt(encrypted)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%d config documents encrypted.\n", n)
+	case *rotateMasterKey:
+		encrypted, ok := store.(*core.EncryptedStore)
+		if !ok || *newMasterKey == "" {
+			return fmt.Errorf("requires [master-key, new-master-key]")
+		}
+
+		key, err := LoadMasterKey(*newMasterKey)
+		if err != nil {
+			return err
+		}
+
+		n, err := core.Reencrypt(&core.EncryptedStore{Store: encrypted.Store, Key: key, PreviousKey: encrypted.Key})
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%d config documents encrypted by the new master key.\n", n)
+	case *copyTo != "":
+		to, err := OpenStore(*copyTo, *baseDir, *masterKey)
+		if err != nil {
+			return err
+		}
+		defer to.Close()
+
+		n, err := core.CopyStore(store, to)
 		if err != nil {
 			return err
 		}
 
-		fmt.Printf("Registry [%s] builder param [%s] has been set to [%s].\n", *registry, *builderParamKey, *builderParamVal)
+		fmt.Printf("%d config documents copied from store [%s] to store [%s].\n", n, *storeType, *copyTo)
 	default:
 		fmt.Println("Nothing to do. Check --help for more information.")
 	}
@@ -0,0 +1,83 @@
+// Copyright 2025 Jelly Terra <jellyterra@proton.me>
+// This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0
+// that can be found in the LICENSE file and https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/autodns/autodns.go/metrics"
+)
+
+var (
+	metricRequests = metrics.NewCounterVec("autodns_http_requests_total",
+		"HTTP requests by route, status and role.", "route", "status", "role")
+	metricRequestDuration = metrics.NewHistogramVec("autodns_http_request_duration_seconds",
+		"Latency of HTTP requests by route.", nil, "route")
+	metricDDNSTriggers = metrics.NewCounterVec("autodns_ddns_triggers_total",
+		"DDNS client triggers.")
+	metricDDNSUpdates = metrics.NewCounterVec("autodns_ddns_updates_total",
+		"DDNS client updates sent to servers by result.", "result")
+	metricWebhookDropped = metrics.NewCounterVec("autodns_webhook_dropped_total",
+		"Webhook events dropped as the delivery queue is full.", "webhook")
+)
+
+// requestInfo is filled in while the request is handled.
+type requestInfo struct {
+	ID   string
+	Role string
+}
+
+type requestInfoKey struct{}
+
+// RequestInfo returns the info attached by Instrument, or a detached one.
+func RequestInfo(r *http.Request) *requestInfo {
+	info, ok := r.Context().Value(requestInfoKey{}).(*requestInfo)
+	if !ok {
+		return &requestInfo{}
+	}
+	return info
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// Instrument counts and measures the requests of the route.
+func Instrument(route string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		info := &requestInfo{ID: NewRequestID()}
+		w := &statusWriter{ResponseWriter: rw}
+		w.Header().Set("X-Request-ID", info.ID)
+
+		handler(w, r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, info)))
+
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		metricRequests.Inc(route, strconv.Itoa(w.status), info.Role)
+		metricRequestDuration.Since(start, route)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2025 Jelly Terra <jellyterra@proton.me>
+// This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0
+// that can be found in the LICENSE file and https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"slices"
+
+	"github.com/autodns/autodns.go/core"
+)
+
+// verify returns the desired records the registry does not hold as they are, compared as in diffRecords.
+func verify(registry core.Registry, desired []*core.Record, ignoreTTL bool) (missing []*core.Record, _ error) {
+	held := map[string][]*core.Record{}
+	for _, record := range desired {
+		if _, exist := held[record.CanonicalName]; exist {
+			continue
+		}
+		records, err := registry.ListRecords(record.CanonicalName)
+		if err != nil {
+			return nil, fmt.Errorf("listing records with domain [%s] failed: %v", record.CanonicalName, err)
+		}
+		held[record.CanonicalName] = records
+	}
+
+	for _, record := range desired {
+		if ignoreTTL {
+			record = withoutTTL(held[record.CanonicalName], []*core.Record{record})[0]
+		}
+		exist := slices.ContainsFunc(held[record.CanonicalName], func(r *core.Record) bool {
+			return core.SameRecord(r, record)
+		})
+		if !exist {
+			missing = append(missing, record)
+		}
+	}
+	return missing, nil
+}
+
+func _migrate(args []string) error {
+	f := flag.NewFlagSet("migrate", flag.ExitOnError)
+	var (
+		z         = newZoneFlags(f, "Domain whose records with the name and under it are migrated. Empty to be all records.")
+		from      = f.String("from", "", "Registry name to read records from.")
+		to        = f.String("to", "", "Registry name to write records into.")
+		prune     = f.Bool("prune", false, "Delete records in the target that are not in the source, except SOA and NS records of the zone apex.")
+		dryRun    = f.Bool("dry-run", false, "Show the changes without applying them.")
+		apexNS    = f.Bool("include-apex-ns", false, "Migrate NS records of the zone apex, which registries usually manage.")
+		ignoreTTL = f.Bool("ignore-ttl", false, "Keep the TTL of records the target holds, as for targets clamping TTL.")
+	)
+	_ = f.Parse(args)
+
+	if *from == "" || *to == "" {
+		return fmt.Errorf("requires [from, to]")
+	}
+	if *from == *to {
+		return fmt.Errorf("source and target are the same registry")
+	}
+
+	registries, err := z.build(*from, *to)
+	if err != nil {
+		return err
+	}
+	source, target := registries[0], registries[1]
+	defer source.Close()
+	defer target.Close()
+
+	desired, err := source.ListRecords(z.origin())
+	if err != nil {
+		return err
+	}
+	apex := apexNames(z.origin(), desired)
+	desired = slices.DeleteFunc(desired, func(record *core.Record) bool {
+		// Registries manage SOA and apex NS records themselves.
+		if record.Type == "SOA" || !*apexNS && record.Type == "NS" && apex[record.CanonicalName] {
+			return true
+		}
+		return !z.included(record)
+	})
+	slices.SortFunc(desired, core.CompareRecords)
+
+	operations, err := z.diffRecords(target, desired, *prune, *ignoreTTL)
+	if err != nil {
+		return err
+	}
+
+	printPlan(operations)
+	if *dryRun || len(operations) == 0 {
+		return nil
+	}
+
+	// Apply name by name, so records the target rejects do not stop the others.
+	var (
+		failures int
+		start    int
+	)
+	for i := range operations {
+		if i+1 < len(operations) && operations[i+1].CanonicalName == operations[start].CanonicalName {
+			continue
+		}
+		err := core.ApplyBatch(target, core.NewBatch(operations[start:i+1]))
+		if err != nil {
+			failures++
+			fmt.Printf("! %s: %v\n", operations[start].CanonicalName, err)
+		}
+		start = i + 1
+	}
+
+	// Verify the records the target holds, as it might reject or alter some silently.
+	// The target is built again, as it might cache the records listed before applying.
+	fresh, err := z.build(*to)
+	if err != nil {
+		return err
+	}
+	defer fresh[0].Close()
+
+	missing, err := verify(fresh[0], desired, *ignoreTTL)
+	if err != nil {
+		return err
+	}
+	for _, record := range missing {
+		fmt.Printf("! %s %s %s %d is not represented in the target.\n", record.CanonicalName, record.Type, record.Value, record.TTL)
+	}
+
+	if failures != 0 || len(missing) != 0 {
+		return fmt.Errorf("%d names failed, %d records not represented", failures, len(missing))
+	}
+
+	fmt.Println("Migrated and verified.")
+	return nil
+}
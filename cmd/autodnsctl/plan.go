@@ -0,0 +1,183 @@
+// Copyright 2025 Jelly Terra <jellyterra@proton.me>
+// This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0
+// that can be found in the LICENSE file and https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/autodns/autodns.go/core"
+)
+
+// Manifest is the desired records of delegated domains.
+type Manifest struct {
+	Version int `json:"version,omitempty"`
+
+	Server string `json:"server"`
+	Role   string `json:"role"`
+	// Key might be a secret reference.
+	Key string `json:"key"`
+
+	Domains map[string][]*core.ManifestRecord `json:"domains"`
+}
+
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err = core.ToJSON(path, data)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := core.DecodeStrict(data, &Manifest{})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	err = core.CheckVersion(manifest.Version)
+	if err != nil {
+		return nil, err
+	}
+	if manifest.Server == "" || manifest.Role == "" || manifest.Key == "" {
+		return nil, fmt.Errorf("%s: requires [server, role, key]", path)
+	}
+
+	manifest.Key, err = core.ResolveSecret(manifest.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// post sends the request to the API of the server and decodes the response.
+func post[T any](client *http.Client, server string, api string, req any, resp *T) (*T, error) {
+	u, err := url.JoinPath(server, api)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := client.Post(u, "application/json", bytes.NewReader(MarshalJSON(req)))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: status %d, request ID %s: %s", api, r.StatusCode, r.Header.Get("X-Request-ID"), b)
+	}
+
+	return UnmarshalJSON(b, resp)
+}
+
+func printPlan(operations []*core.Operation) {
+	if len(operations) == 0 {
+		fmt.Println("No changes.")
+		return
+	}
+
+	for _, op := range operations {
+		sign := map[string]string{core.OP_APPEND: "+", core.OP_DELETE: "-"}[op.Op]
+		fmt.Printf("%s %s %s %s %d\n", sign, op.CanonicalName, op.Type, op.Value, op.TTL)
+	}
+	fmt.Printf("%d operations.\n", len(operations))
+}
+
+// plan loads the manifest and asks the server for the plan.
+func plan(manifestPath string, prune bool) (*Manifest, []*core.Operation, error) {
+	if manifestPath == "" {
+		return nil, nil, fmt.Errorf("requires [f], optional [prune]")
+	}
+
+	manifest, err := LoadManifest(manifestPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := post(&http.Client{Timeout: time.Minute}, manifest.Server, "/v1/plan", &ReqPlan{
+		Role:    manifest.Role,
+		Token:   manifest.Key,
+		Domains: manifest.Domains,
+		Prune:   prune,
+	}, &RespPlan{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return manifest, resp.Operations, nil
+}
+
+func _plan(args []string) error {
+	f := flag.NewFlagSet("plan", flag.ExitOnError)
+	var (
+		manifestPath = f.String("f", "", "Path to manifest in JSON, YAML or TOML.")
+		prune        = f.Bool("prune", false, "Delete records under the domains that are not listed.")
+	)
+	_ = f.Parse(args)
+
+	_, operations, err := plan(*manifestPath, *prune)
+	if err != nil {
+		return err
+	}
+
+	printPlan(operations)
+	return nil
+}
+
+func _apply(args []string) error {
+	f := flag.NewFlagSet("apply", flag.ExitOnError)
+	var (
+		manifestPath = f.String("f", "", "Path to manifest in JSON, YAML or TOML.")
+		prune        = f.Bool("prune", false, "Delete records under the domains that are not listed.")
+		atomic       = f.Bool("atomic", true, "Apply the operations all or nothing.")
+		autoApprove  = f.Bool("auto-approve", false, "Apply without asking for confirmation.")
+	)
+	_ = f.Parse(args)
+
+	manifest, operations, err := plan(*manifestPath, *prune)
+	if err != nil {
+		return err
+	}
+
+	printPlan(operations)
+	if len(operations) == 0 {
+		return nil
+	}
+
+	if !*autoApprove {
+		fmt.Print("Apply these operations? Only 'yes' will be accepted: ")
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if strings.TrimSpace(answer) != "yes" {
+			return fmt.Errorf("apply cancelled")
+		}
+	}
+
+	_, err = post(&http.Client{Timeout: 10 * time.Minute}, manifest.Server, "/v1/do", &ReqDo{
+		Role:       manifest.Role,
+		Token:      manifest.Key,
+		Operations: operations,
+		Atomic:     *atomic,
+	}, &struct{}{})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Applied.")
+	return nil
+}
@@ -0,0 +1,134 @@
+// Copyright 2025 Jelly Terra <jellyterra@proton.me>
+// This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0
+// that can be found in the LICENSE file and https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/autodns/autodns.go/core"
+)
+
+// schemaTypes returns the JSON types allowed by the schema node.
+func schemaTypes(node map[string]any) []string {
+	switch typ := node["type"].(type) {
+	case string:
+		return []string{typ}
+	case []any:
+		var types []string
+		for _, t := range typ {
+			types = append(types, t.(string))
+		}
+		return types
+	}
+	return nil
+}
+
+// checkSchema compares the schema node with the Go type it describes, field by field.
+func checkSchema(t *testing.T, at string, typ reflect.Type, node map[string]any) {
+	t.Helper()
+
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	var want string
+	switch typ.Kind() {
+	case reflect.String:
+		want = "string"
+	case reflect.Bool:
+		want = "boolean"
+	case reflect.Int, reflect.Int64:
+		want = "integer"
+	case reflect.Slice:
+		want = "array"
+	case reflect.Map, reflect.Struct:
+		want = "object"
+	default:
+		t.Fatalf("%s: unexpected kind %s", at, typ.Kind())
+	}
+	if !slices.Contains(schemaTypes(node), want) {
+		t.Errorf("%s: schema type %v, want %s", at, node["type"], want)
+		return
+	}
+
+	switch typ.Kind() {
+	case reflect.Slice:
+		items, _ := node["items"].(map[string]any)
+		if items == nil {
+			t.Errorf("%s: schema has no items", at)
+			return
+		}
+		checkSchema(t, at+"[]", typ.Elem(), items)
+	case reflect.Map:
+		values, _ := node["additionalProperties"].(map[string]any)
+		if values == nil {
+			t.Errorf("%s: schema has no additionalProperties", at)
+			return
+		}
+		checkSchema(t, at+"{}", typ.Elem(), values)
+	case reflect.Struct:
+		if node["additionalProperties"] != false {
+			t.Errorf("%s: schema allows additional properties", at)
+		}
+
+		properties, _ := node["properties"].(map[string]any)
+		fields := map[string]bool{}
+		for i := range typ.NumField() {
+			field := typ.Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if !field.IsExported() || name == "-" || name == "" {
+				continue
+			}
+			fields[name] = true
+
+			property, _ := properties[name].(map[string]any)
+			if property == nil {
+				t.Errorf("%s.%s: missing in schema", at, name)
+				continue
+			}
+			checkSchema(t, at+"."+name, field.Type, property)
+		}
+		for name := range properties {
+			if !fields[name] {
+				t.Errorf("%s.%s: missing in %s", at, name, typ)
+			}
+		}
+
+		required, _ := node["required"].([]any)
+		for _, name := range required {
+			if !fields[name.(string)] {
+				t.Errorf("%s.%s: required but missing in %s", at, name, typ)
+			}
+		}
+	}
+}
+
+func TestSchemas(t *testing.T) {
+	for file, v := range map[string]any{
+		"role":     core.RoleDef{},
+		"registry": core.RegistryDef{},
+		"webhook":  core.WebhookDef{},
+		"ddns":     DDNSConfig{},
+	} {
+		t.Run(file, func(t *testing.T) {
+			b, err := os.ReadFile("../../schema/" + file + ".schema.json")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var schema map[string]any
+			err = json.Unmarshal(b, &schema)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkSchema(t, file, reflect.TypeOf(v), schema)
+		})
+	}
+}
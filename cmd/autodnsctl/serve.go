@@ -6,24 +6,53 @@ package main
 
 import (
 	"context"
+	"crypto/subtle"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"log/slog"
 	"net/http"
-	"os"
+	"net/netip"
 	"path"
+	"strings"
 	"time"
 
 	"github.com/autodns/autodns.go/core"
+	"github.com/autodns/autodns.go/metrics"
 )
 
+// trackedWriter remembers whether the handler has responded by itself.
+type trackedWriter struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (w *trackedWriter) WriteHeader(code int) {
+	w.written = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *trackedWriter) Write(b []byte) (int, error) {
+	w.written = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *trackedWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
 func HandleWrap(handler func(w http.ResponseWriter, r *http.Request) (int, error, error)) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		w := &trackedWriter{ResponseWriter: rw}
+		r.Body = http.MaxBytesReader(rw, r.Body, MaxRequestBytes)
+
 		code, err, iErr := handler(w, r)
+		if tooLarge := (*http.MaxBytesError)(nil); errors.As(err, &tooLarge) {
+			code = http.StatusRequestEntityTooLarge
+		}
 		switch {
 		case iErr != nil:
 			w.WriteHeader(http.StatusInternalServerError)
-			fmt.Println(iErr)
+			Logger(r).Error("internal error", "path", r.URL.Path, "error", iErr)
 		case err != nil:
 			if code != 0 {
 				w.WriteHeader(code)
@@ -35,23 +64,218 @@ func HandleWrap(handler func(w http.ResponseWriter, r *http.Request) (int, error
 			}{
 				Error: err.Error(),
 			})))
+		case w.written:
 		default:
 			_, _ = w.Write([]byte("{}"))
 		}
 	}
 }
 
+// MaxRequestBytes limits the body of API requests, which is read before its operations are counted.
+const MaxRequestBytes = 4 << 20
+
 type ReqDo struct {
 	Role  string `json:"role"`
 	Token string `json:"token"`
 
 	Operations []*core.Operation `json:"operations"`
+
+	// Async queues the operations as a job and returns its ID at once.
+	Async bool `json:"async"`
+
+	// Atomic applies the operations all or nothing.
+	Atomic bool `json:"atomic"`
+}
+
+type RespJob struct {
+	JobID string `json:"job_id"`
+}
+
+type ReqRollback struct {
+	Role  string `json:"role"`
+	Token string `json:"token"`
+
+	core.AuditFilter
+}
+
+type RespRollback struct {
+	Restored []*core.RollbackResult `json:"restored"`
+}
+
+type ReqPlan struct {
+	Role  string `json:"role"`
+	Token string `json:"token"`
+
+	Domains map[string][]*core.ManifestRecord `json:"domains"`
+	Prune   bool                              `json:"prune"`
+}
+
+type RespPlan struct {
+	Operations []*core.Operation `json:"operations"`
 }
 
-func Serve(ctx context.Context, c *core.Context, addr string, route string) error {
-	mux := http.NewServeMux()
+type RespRecords struct {
+	Records []*core.Record `json:"records"`
+}
+
+type RespIP struct {
+	IP string `json:"ip"`
+}
+
+// Authorize looks up the role and checks the key against it.
+func Authorize(r *http.Request, c *core.Context, role string, token string) (*core.RoleDef, int, error, error) {
+	roleDef, err := core.Query(c, &core.RoleDef{}, "role", role)
+	switch {
+	case err == nil:
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, core.ErrInvalidName):
+		return nil, http.StatusUnauthorized, fmt.Errorf("authorization failed"), nil
+	default:
+		return nil, 0, nil, err
+	}
+
+	key, ok := roleDef.Keys[token]
+	if !ok || key.Expire != 0 && key.Expire < time.Now().Unix() {
+		return nil, http.StatusUnauthorized, fmt.Errorf("authorization failed"), nil
+	}
+	RequestInfo(r).Role = role
+
+	return roleDef, 0, nil, nil
+}
+
+// HasBearer reports whether the request has the bearer token, which must not be empty.
+func HasBearer(r *http.Request, token string) bool {
+	bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && token != "" && subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) == 1
+}
+
+// RequireBearer serves the handler only to requests with the bearer token.
+func RequireBearer(token string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !HasBearer(r, token) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+type ServeOptions struct {
+	Addr  string
+	Route string
+
+	// TrustedProxies are the peers whose forwarding headers are believed.
+	TrustedProxies []netip.Prefix
+
+	// MonitoringToken authorizes /metrics and the details of /readyz as bearer token. Empty to disable /metrics.
+	MonitoringToken string
 
-	mux.HandleFunc(path.Join(route, "/v1/do"), HandleWrap(func(w http.ResponseWriter, r *http.Request) (int, error, error) {
+	// ReadyCheckRegistries makes readiness build every registry, authenticating against the provider.
+	ReadyCheckRegistries bool
+	ReadyCacheLifetime   int64
+
+	// Audit records every operation. Nil to be disabled.
+	Audit *core.AuditLog
+
+	// Webhooks are notified of every operation. Nil to be disabled.
+	Webhooks *Webhooks
+
+	// Events streams changes to subscribers.
+	Events *Broker
+
+	// Jobs executes asynchronous requests.
+	Jobs *Jobs
+}
+
+// Observe returns the callback that logs and audits the result of each operation of the request.
+func Observe(r *http.Request, opts *ServeOptions, role string, token string) func(err error, op *core.Operation) {
+	observe := ObserveRecords(r, opts, role, token)
+
+	return func(err error, op *core.Operation) {
+		var records []*core.Record
+		if op.Op != core.OP_DELETE && op.Op != core.OP_REQUEST {
+			records = []*core.Record{&op.Record}
+		}
+		observe(err, op, records)
+	}
+}
+
+// ObserveRecords is Observe with the records written by the operation.
+// The request is only read before returning, so the callback can outlive it.
+func ObserveRecords(r *http.Request, opts *ServeOptions, role string, token string) func(err error, op *core.Operation, records []*core.Record) {
+	var (
+		logger    = Logger(r).With("role", role)
+		requestID = RequestInfo(r).ID
+		sourceIP  string
+	)
+	if clientAddr, err := ClientAddr(r, opts.TrustedProxies); err == nil {
+		sourceIP = clientAddr.String()
+	}
+
+	return func(err error, op *core.Operation, records []*core.Record) {
+		LogOperation(logger, err, op)
+
+		event := &Event{
+			Type:      EVENT_APPLIED,
+			Time:      time.Now().Unix(),
+			Role:      role,
+			RequestID: requestID,
+			Registry:  op.Registry,
+			Op:        op.Op,
+			Name:      op.CanonicalName,
+			Records:   records,
+		}
+		if err != nil {
+			event.Type = EVENT_FAILED
+			event.Error = err.Error()
+		}
+		if opts.Webhooks != nil {
+			opts.Webhooks.Dispatch(event)
+		}
+		if opts.Events != nil {
+			opts.Events.Publish(event)
+		}
+
+		if opts.Audit == nil {
+			return
+		}
+
+		entry := &core.AuditEntry{
+			Time:      time.Now().Unix(),
+			Role:      role,
+			KeyID:     core.KeyID(token),
+			SourceIP:  sourceIP,
+			RequestID: requestID,
+			Registry:  op.Registry,
+			Op:        op.Op,
+			Name:      op.CanonicalName,
+			Prior:     op.Prior,
+			New:       records,
+			Result:    "success",
+		}
+		if err != nil {
+			entry.Result = "failure"
+			entry.Error = err.Error()
+		}
+
+		err = opts.Audit.Write(entry)
+		if err != nil {
+			logger.Error("writing audit log failed", "error", err)
+		}
+	}
+}
+
+func Serve(ctx context.Context, c *core.Context, opts *ServeOptions) error {
+	var (
+		mux   = http.NewServeMux()
+		route = opts.Route
+	)
+
+	handle := func(method string, pattern string, handler http.HandlerFunc) {
+		mux.HandleFunc(strings.TrimSpace(method+" "+path.Join(route, pattern)), Instrument(pattern, handler))
+	}
+
+	handle("", "/v1/do", HandleWrap(func(w http.ResponseWriter, r *http.Request) (int, error, error) {
 		b, err := io.ReadAll(r.Body)
 		if err != nil {
 			return 0, err, nil
@@ -61,46 +285,243 @@ func Serve(ctx context.Context, c *core.Context, addr string, route string) erro
 		if err != nil {
 			return 0, err, nil
 		}
+		for _, op := range req.Operations {
+			if op == nil {
+				return 0, fmt.Errorf("null operation"), nil
+			}
+			// The registry is resolved by the delegation, never taken from the client.
+			op.Registry = ""
+		}
+
+		roleDef, code, err, iErr := Authorize(r, c, req.Role, req.Token)
+		if err != nil || iErr != nil {
+			return code, err, iErr
+		}
+
+		err = ResolveClientAddr(req.Operations, func() (netip.Addr, error) {
+			return ClientAddr(r, opts.TrustedProxies)
+		})
+		if err != nil {
+			return 0, err, nil
+		}
+
+		observe := Observe(r, opts, req.Role, req.Token)
+
+		err = core.CheckOperations(c, req.Operations)
+		if err != nil {
+			core.RejectRequest(err, observe)
+			return http.StatusRequestEntityTooLarge, err, nil
+		}
+
+		execute := core.ExecuteAll
+		if req.Atomic {
+			execute = core.ExecuteAtomic
+		}
+
+		if req.Async {
+			// Rejected requests are answered at once rather than as failed jobs.
+			err = core.ValidateOperations(roleDef, req.Operations)
+			if err != nil {
+				core.Reject(req.Operations, err, observe)
+				if errors.Is(err, core.ErrPermissionDenied) {
+					return http.StatusForbidden, err, nil
+				}
+				return 0, err, nil
+			}
+
+			job, err := opts.Jobs.Submit(req.Role, req.Operations, func(callback func(err error, op *core.Operation)) error {
+				return execute(c, roleDef, req.Operations, func(err error, op *core.Operation) {
+					observe(err, op)
+					callback(err, op)
+				})
+			})
+			if err != nil {
+				return http.StatusServiceUnavailable, err, nil
+			}
+
+			w.WriteHeader(http.StatusAccepted)
+			_, _ = w.Write(MarshalJSON(&RespJob{JobID: job.ID}))
+			return 0, nil, nil
+		}
 
-		roleDef, err := core.Query(c, &core.RoleDef{}, "role", req.Role)
+		err = execute(c, roleDef, req.Operations, observe)
 		switch {
 		case err == nil:
-		case os.IsNotExist(err):
-			return http.StatusUnauthorized, fmt.Errorf("authorization failed"), nil
+		case errors.Is(err, core.ErrPermissionDenied):
+			return http.StatusForbidden, err, nil
+		case errors.Is(err, core.ErrRolledBack):
+			return http.StatusBadGateway, err, nil
 		default:
 			return 0, nil, err
 		}
 
-		key, ok := roleDef.Keys[req.Token]
-		if !ok || key.Expire != 0 && key.Expire < time.Now().Unix() {
-			return http.StatusUnauthorized, fmt.Errorf("authorization failed"), nil
+		return 0, nil, nil
+	}))
+
+	handle("GET", "/v1/jobs/{id}", HandleWrap(func(w http.ResponseWriter, r *http.Request) (int, error, error) {
+		role, token, _ := r.BasicAuth()
+		roleDef, code, err, iErr := Authorize(r, c, role, token)
+		if err != nil || iErr != nil {
+			return code, err, iErr
+		}
+
+		job := opts.Jobs.Get(r.PathValue("id"))
+		if job == nil || job.Role != role && !roleDef.Admin {
+			return http.StatusNotFound, fmt.Errorf("job not found"), nil
+		}
+
+		_, _ = w.Write(MarshalJSON(job.Snapshot()))
+		return 0, nil, nil
+	}))
+
+	handle("POST", "/v1/admin/rollback", HandleWrap(func(w http.ResponseWriter, r *http.Request) (int, error, error) {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			return 0, err, nil
+		}
+
+		req, err := UnmarshalJSON(b, &ReqRollback{})
+		if err != nil {
+			return 0, err, nil
+		}
+
+		roleDef, code, err, iErr := Authorize(r, c, req.Role, req.Token)
+		if err != nil || iErr != nil {
+			return code, err, iErr
+		}
+		if !roleDef.Admin {
+			return http.StatusForbidden, core.ErrPermissionDenied, nil
+		}
+
+		if opts.Audit == nil {
+			return http.StatusNotImplemented, fmt.Errorf("audit log is disabled"), nil
+		}
+		if req.AuditFilter.Empty() {
+			return 0, fmt.Errorf("requires one of [name, role, request_id, since]"), nil
+		}
+
+		entries, err := core.ReadAuditLog(opts.Audit.Path)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		results, err := core.Rollback(c, core.FilterAuditEntries(entries, &req.AuditFilter))
+		if err != nil {
+			return 0, err, nil
 		}
 
-		err = core.ExecuteAll(c, roleDef, req.Operations, func(err error, op *core.Operation) {
-			switch op.Op {
-			case core.OP_UPDATE:
-				fmt.Printf("Role [%s] updates [%s] => [%s] with TTL [%d]", req.Role, op.CanonicalName, op.Value, op.TTL)
-			case core.OP_DELETE:
-				fmt.Printf("Role [%s] deletes [%s]", req.Role, op.CanonicalName)
+		observe := ObserveRecords(r, opts, req.Role, req.Token)
+		for _, result := range results {
+			op := &core.Operation{
+				Op:       core.OP_ROLLBACK,
+				Registry: result.Registry,
+				Prior:    result.Prior,
 			}
-			if err != nil {
-				fmt.Println(", failed:", err)
-			} else {
-				fmt.Print("\n")
+			op.CanonicalName = result.Name
+
+			err = nil
+			if result.Error != "" {
+				err = errors.New(result.Error)
 			}
-		})
+			observe(err, op, result.Records)
+		}
+
+		_, _ = w.Write(MarshalJSON(&RespRollback{Restored: results}))
+		return 0, nil, nil
+	}))
+
+	handle("POST", "/v1/plan", HandleWrap(func(w http.ResponseWriter, r *http.Request) (int, error, error) {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			return 0, err, nil
+		}
+
+		req, err := UnmarshalJSON(b, &ReqPlan{})
 		if err != nil {
+			return 0, err, nil
+		}
+
+		roleDef, code, err, iErr := Authorize(r, c, req.Role, req.Token)
+		if err != nil || iErr != nil {
+			return code, err, iErr
+		}
+
+		operations, err := core.Plan(c, roleDef, req.Domains, req.Prune)
+		switch {
+		case err == nil:
+		case errors.Is(err, core.ErrPermissionDenied):
+			return http.StatusForbidden, err, nil
+		default:
 			return 0, nil, err
 		}
+		if operations == nil {
+			operations = []*core.Operation{}
+		}
 
+		_, _ = w.Write(MarshalJSON(&RespPlan{Operations: operations}))
 		return 0, nil, nil
 	}))
+	handle("GET", "/v1/records", HandleWrap(func(w http.ResponseWriter, r *http.Request) (int, error, error) {
+		role, token, _ := r.BasicAuth()
+		roleDef, code, err, iErr := Authorize(r, c, role, token)
+		if err != nil || iErr != nil {
+			return code, err, iErr
+		}
+
+		records, err := core.ListRecords(c, roleDef, r.URL.Query().Get("domain"))
+		switch {
+		case err == nil:
+		case errors.Is(err, core.ErrPermissionDenied):
+			return http.StatusForbidden, err, nil
+		default:
+			return 0, nil, err
+		}
+		if records == nil {
+			records = []*core.Record{}
+		}
+
+		_, _ = w.Write(MarshalJSON(&RespRecords{Records: records}))
+		return 0, nil, nil
+	}))
+
+	if opts.Events != nil {
+		handle("GET", "/v1/events", HandleEvents(c, opts.Events))
+	}
+
+	handle("", "/v1/ip", HandleWrap(func(w http.ResponseWriter, r *http.Request) (int, error, error) {
+		clientAddr, err := ClientAddr(r, opts.TrustedProxies)
+		switch {
+		case err == nil:
+		case errors.Is(err, ErrClientAddr):
+			return http.StatusBadRequest, err, nil
+		default:
+			return 0, nil, err
+		}
+
+		_, _ = w.Write(MarshalJSON(&RespIP{IP: clientAddr.String()}))
+		return 0, nil, nil
+	}))
+
+	handle("", "/acme/update", HandleACMEDNSUpdate(c, opts))
+	handle("", "/acme/present", HandleHTTPReq(c, opts, core.OP_APPEND))
+	handle("", "/acme/cleanup", HandleHTTPReq(c, opts, core.OP_DELETE))
+
+	if opts.MonitoringToken != "" {
+		mux.Handle(path.Join(route, "/metrics"), RequireBearer(opts.MonitoringToken, metrics.Handler()))
+	}
+	mux.HandleFunc(path.Join(route, "/healthz"), HandleHealth)
+	mux.Handle(path.Join(route, "/readyz"), &Readiness{
+		C:             c,
+		Token:         opts.MonitoringToken,
+		CheckRegistry: opts.ReadyCheckRegistries,
+		CacheLifetime: opts.ReadyCacheLifetime,
+	})
 
-	s := http.Server{Addr: addr, Handler: mux}
+	s := http.Server{Addr: opts.Addr, Handler: mux}
 
 	go func() {
 		<-ctx.Done()
-		fmt.Println("Shutting down")
+		slog.Info("shutting down")
 		_ = s.Shutdown(ctx)
 	}()
 
@@ -0,0 +1,32 @@
+// Copyright 2025 Jelly Terra <jellyterra@proton.me>
+// This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0
+// that can be found in the LICENSE file and https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"github.com/autodns/autodns.go/core"
+)
+
+// LoadMasterKey resolves the master key, which might be a secret reference.
+func LoadMasterKey(masterKey string) (*[32]byte, error) {
+	s, err := core.ResolveSecret(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	return core.ParseMasterKey(s)
+}
+
+// OpenStore opens the config store, encrypted at rest if the master key is given.
+func OpenStore(typ string, dir string, masterKey string) (core.Store, error) {
+	store, err := core.OpenStore(typ, dir)
+	if err != nil || masterKey == "" {
+		return store, err
+	}
+
+	key, err := LoadMasterKey(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	return &core.EncryptedStore{Store: store, Key: key}, nil
+}
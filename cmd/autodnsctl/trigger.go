@@ -8,20 +8,8 @@ package main
 
 import (
 	"context"
-	"time"
 )
 
 func Trigger(ctx context.Context, triggerTime int, c chan<- struct{}) error {
 	return TimerNotify(ctx, triggerTime, c)
 }
-
-func TimerNotify(ctx context.Context, triggerTime int, c chan<- struct{}) error {
-	for {
-		select {
-		case <-time.After(time.Duration(triggerTime) * time.Second):
-			c <- struct{}{}
-		case <-ctx.Done():
-			return nil
-		}
-	}
-}
@@ -0,0 +1,114 @@
+// Copyright 2025 Jelly Terra <jellyterra@proton.me>
+// This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0
+// that can be found in the LICENSE file and https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+
+	"github.com/autodns/autodns.go/core"
+)
+
+// ValidateDDNSConfig checks the DDNS config file.
+func ValidateDDNSConfig(configPath string) (issues []*core.Issue) {
+	report := func(severity string, format string, a ...any) {
+		issues = append(issues, &core.Issue{Config: configPath, Severity: severity, Message: fmt.Sprintf(format, a...)})
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		report(core.ISSUE_ERROR, "%v", err)
+		return issues
+	}
+
+	data, err = core.ToJSON(configPath, data)
+	if err != nil {
+		report(core.ISSUE_ERROR, "%v", err)
+		return issues
+	}
+
+	config, err := core.DecodeStrict(data, &DDNSConfig{})
+	if err != nil {
+		report(core.ISSUE_ERROR, "%v", err)
+		return issues
+	}
+	if err = core.CheckVersion(config.Version); err != nil {
+		report(core.ISSUE_ERROR, "%v", err)
+	}
+
+	var addrSets []string
+	for _, addrSet := range config.AddrSets {
+		addrSets = append(addrSets, addrSet.Name)
+		for _, rule := range addrSet.Rules {
+			_, err := regexp.Compile(rule.Glob)
+			if err != nil {
+				report(core.ISSUE_ERROR, "addr set [%s] rule glob pattern: %v", addrSet.Name, err)
+			}
+		}
+	}
+
+	for _, zone := range config.Zones {
+		if zone.Server == "" || zone.Role == "" || zone.Key == "" {
+			report(core.ISSUE_ERROR, "zone of server [%s] requires [server, role, key]", zone.Server)
+		}
+		for _, record := range zone.Records {
+			for _, addrSet := range record.AddrSets {
+				if !slices.Contains(addrSets, addrSet) {
+					report(core.ISSUE_ERROR, "record [%s] of domain [%s] refers to addr set [%s] which does not exist", record.Subdomain, record.Domain, addrSet)
+				}
+			}
+		}
+	}
+
+	return issues
+}
+
+func _validate(args []string) error {
+	f := flag.NewFlagSet("validate", flag.ExitOnError)
+	var (
+		baseDir    = f.String("config-dir", ".", "Base directory for reading config in JSON.")
+		storeType  = f.String("store", "fs", "Config store: fs, bolt.")
+		masterKey  = f.String("master-key", "", "Master key in base64 of 32 bytes to encrypt config at rest, or its reference env:NAME, file:PATH, cred:NAME. Empty to be disabled.")
+		ddnsConfig = f.String("ddns-config", "", "Path to DDNS config file to validate instead.")
+		asJSON     = f.Bool("json", false, "Print issues in JSONL.")
+	)
+	_ = f.Parse(args)
+
+	var issues []*core.Issue
+	if *ddnsConfig != "" {
+		issues = ValidateDDNSConfig(*ddnsConfig)
+	} else {
+		store, err := OpenStore(*storeType, *baseDir, *masterKey)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		issues, err = core.ValidateConfig(store)
+		if err != nil {
+			return err
+		}
+	}
+
+	errors := 0
+	for _, issue := range issues {
+		if issue.Severity == core.ISSUE_ERROR {
+			errors++
+		}
+		if *asJSON {
+			fmt.Println(string(MarshalJSON(issue)))
+		} else {
+			fmt.Println(issue)
+		}
+	}
+
+	if errors != 0 {
+		return fmt.Errorf("%d errors found", errors)
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+// Copyright 2025 Jelly Terra <jellyterra@proton.me>
+// This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0
+// that can be found in the LICENSE file and https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/autodns/autodns.go/core"
+)
+
+// Sign returns the signature of the payload sent in X-Autodns-Signature.
+func Sign(secret string, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+type DeadLetter struct {
+	Time    int64  `json:"time"`
+	Webhook string `json:"webhook"`
+	URL     string `json:"url"`
+	Payload *Event `json:"payload"`
+	Error   string `json:"error"`
+}
+
+// Webhooks delivers events to the webhooks defined in webhook/*.json.
+type Webhooks struct {
+	C *core.Context
+
+	// Ctx stops the retries in progress, leaving their events to the dead letters.
+	Ctx context.Context
+
+	// Retries after the first delivery fails, with doubling backoff from one second.
+	Retries int
+
+	// DeadLetterPath is the JSONL file where undeliverable events are appended. Empty to be logged only.
+	DeadLetterPath string
+
+	client         http.Client
+	deadLetterLock sync.Mutex
+	wg             sync.WaitGroup
+
+	queue     chan *delivery
+	queueLock sync.Mutex
+}
+
+// delivery is an event to deliver to a webhook.
+type delivery struct {
+	name  string
+	def   *core.WebhookDef
+	event *Event
+}
+
+// NewWebhooks starts the workers delivering events from a queue of the size.
+func NewWebhooks(ctx context.Context, c *core.Context, workers int, queueSize int, retries int, deadLetterPath string) *Webhooks {
+	wh := &Webhooks{
+		C:              c,
+		Ctx:            ctx,
+		Retries:        retries,
+		DeadLetterPath: deadLetterPath,
+		client:         http.Client{Timeout: 10 * time.Second},
+		queue:          make(chan *delivery, queueSize),
+	}
+
+	for range workers {
+		go func() {
+			for {
+				select {
+				case d := <-wh.queue:
+					if ctx.Err() != nil {
+						wh.giveUp(d, fmt.Errorf("delivery stopped: %v", ctx.Err()))
+						wh.wg.Done()
+						continue
+					}
+					wh.deliver(d)
+				case <-ctx.Done():
+					wh.drain()
+					return
+				}
+			}
+		}()
+	}
+
+	return wh
+}
+
+// drain gives up the deliveries left in the queue. Dispatch is locked out, so none is queued after it.
+func (wh *Webhooks) drain() {
+	wh.queueLock.Lock()
+	defer wh.queueLock.Unlock()
+
+	for {
+		select {
+		case d := <-wh.queue:
+			wh.giveUp(d, fmt.Errorf("delivery stopped: %v", wh.Ctx.Err()))
+			wh.wg.Done()
+		default:
+			return
+		}
+	}
+}
+
+// Dispatch queues the event for every matching webhook. Events are dropped if the queue is full.
+func (wh *Webhooks) Dispatch(event *Event) {
+	names, err := core.QueryNames(wh.C, "webhook")
+	if err != nil {
+		slog.Error("listing webhooks failed", "error", err)
+		return
+	}
+
+	for _, name := range names {
+		def, err := core.Query(wh.C, &core.WebhookDef{}, "webhook", name)
+		if err != nil {
+			slog.Error("loading webhook failed", "webhook", name, "error", err)
+			continue
+		}
+		if !def.Match(event.Role, event.Registry) {
+			continue
+		}
+
+		d := &delivery{name: name, def: def, event: event}
+		wh.wg.Add(1)
+
+		var dropped error
+		wh.queueLock.Lock()
+		if wh.Ctx.Err() != nil {
+			dropped = fmt.Errorf("delivery stopped: %v", wh.Ctx.Err())
+		} else {
+			select {
+			case wh.queue <- d:
+			default:
+				metricWebhookDropped.Inc(name)
+				dropped = fmt.Errorf("delivery queue full")
+			}
+		}
+		wh.queueLock.Unlock()
+
+		if dropped != nil {
+			wh.giveUp(d, dropped)
+			wh.wg.Done()
+		}
+	}
+}
+
+func (wh *Webhooks) deliver(d *delivery) {
+	defer wh.wg.Done()
+
+	err := wh.retry(d.def, MarshalJSON(d.event), slog.With("webhook", d.name, "request_id", d.event.RequestID))
+	if err != nil {
+		wh.giveUp(d, err)
+	}
+}
+
+// giveUp logs the delivery and appends it to the dead letters.
+func (wh *Webhooks) giveUp(d *delivery, err error) {
+	logger := slog.With("webhook", d.name, "request_id", d.event.RequestID)
+	logger.Error("webhook delivery given up", "error", err)
+	if wh.DeadLetterPath == "" {
+		return
+	}
+
+	wh.deadLetterLock.Lock()
+	defer wh.deadLetterLock.Unlock()
+
+	f, dErr := os.OpenFile(wh.DeadLetterPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if dErr != nil {
+		logger.Error("writing dead letter failed", "error", dErr)
+		return
+	}
+	defer f.Close()
+
+	_, dErr = f.Write(append(MarshalJSON(&DeadLetter{
+		Time:    time.Now().Unix(),
+		Webhook: d.name,
+		URL:     d.def.URL,
+		Payload: d.event,
+		Error:   err.Error(),
+	}), '\n'))
+	if dErr != nil {
+		logger.Error("writing dead letter failed", "error", dErr)
+	}
+}
+
+// retry posts the payload until it is delivered, the retries run out or the context is done.
+func (wh *Webhooks) retry(def *core.WebhookDef, payload []byte, logger *slog.Logger) error {
+	secret, err := core.ResolveSecret(def.Secret)
+	if err != nil {
+		return fmt.Errorf("secret: %v", err)
+	}
+
+	for attempt := 0; ; attempt++ {
+		err = wh.post(def, secret, payload)
+		if err == nil {
+			return nil
+		}
+		logger.Warn("webhook delivery failed", "attempt", attempt+1, "error", err)
+
+		if attempt == wh.Retries {
+			return err
+		}
+
+		timer := time.NewTimer(time.Second << attempt)
+		select {
+		case <-timer.C:
+		case <-wh.Ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("retries stopped: %v: %v", wh.Ctx.Err(), err)
+		}
+	}
+}
+
+func (wh *Webhooks) post(def *core.WebhookDef, secret string, payload []byte) error {
+	req, err := http.NewRequest(http.MethodPost, def.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Autodns-Timestamp", timestamp)
+	if secret != "" {
+		req.Header.Set("X-Autodns-Signature", Sign(secret, timestamp, payload))
+	}
+
+	resp, err := wh.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Wait waits for deliveries queued and in progress.
+func (wh *Webhooks) Wait() {
+	wh.wg.Wait()
+}
@@ -0,0 +1,57 @@
+// Copyright 2025 Jelly Terra <jellyterra@proton.me>
+// This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0
+// that can be found in the LICENSE file and https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/autodns/autodns.go/core"
+)
+
+func TestWebhooksQueueFull(t *testing.T) {
+	dir := t.TempDir()
+	store := &core.FSStore{Dir: dir}
+	err := os.Mkdir(path.Join(dir, "webhook"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = core.Save(store, "webhook", "chatops", &core.WebhookDef{URL: "http://127.0.0.1:1/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &core.Context{Store: store, Cache: map[string]*core.ContextCache{}}
+	deadLetter := path.Join(dir, "dead-letter.jsonl")
+
+	// No workers, so only the queue holds the events.
+	ctx, cancel := context.WithCancel(context.Background())
+	wh := NewWebhooks(ctx, c, 0, 1, 0, deadLetter)
+	for range 3 {
+		wh.Dispatch(&Event{Type: EVENT_APPLIED, Role: "jellyterra"})
+	}
+
+	b, err := os.ReadFile(deadLetter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(b), "delivery queue full"); n != 2 {
+		t.Errorf("%d events dropped, want 2:\n%s", n, b)
+	}
+
+	// Events dispatched once stopped are given up rather than queued.
+	cancel()
+	wh.Dispatch(&Event{Type: EVENT_APPLIED, Role: "jellyterra"})
+	b, err = os.ReadFile(deadLetter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(b), "delivery stopped"); n != 1 {
+		t.Errorf("%d events stopped, want 1:\n%s", n, b)
+	}
+}
@@ -0,0 +1,276 @@
+// Copyright 2025 Jelly Terra <jellyterra@proton.me>
+// This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0
+// that can be found in the LICENSE file and https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/autodns/autodns.go/core"
+	"github.com/autodns/autodns.go/zonefile"
+)
+
+// zoneFlags are the flags shared by the subcommands reading registries directly.
+type zoneFlags struct {
+	baseDir   *string
+	storeType *string
+	masterKey *string
+	domain    *string
+	types     *string
+}
+
+func newZoneFlags(f *flag.FlagSet, domainUsage string) *zoneFlags {
+	return &zoneFlags{
+		baseDir:   f.String("config-dir", ".", "Base directory for reading config in JSON."),
+		storeType: f.String("store", "fs", "Config store: fs, bolt."),
+		masterKey: f.String("master-key", "", "Master key in base64 of 32 bytes to encrypt config at rest, or its reference env:NAME, file:PATH, cred:NAME. Empty to be disabled."),
+		domain:    f.String("domain", "", domainUsage),
+		types:     f.String("types", "", "Comma-separated record types to include. Empty to be all."),
+	}
+}
+
+// origin returns the domain without trailing dot.
+func (z *zoneFlags) origin() string {
+	return strings.TrimSuffix(*z.domain, ".")
+}
+
+// build builds the registries by their names in order.
+func (z *zoneFlags) build(names ...string) ([]core.Registry, error) {
+	store, err := OpenStore(*z.storeType, *z.baseDir, *z.masterKey)
+	if err != nil {
+		return nil, err
+	}
+	// Registries are built from the documents at once.
+	defer store.Close()
+
+	c := &core.Context{
+		Store: store,
+		Cache: map[string]*core.ContextCache{},
+	}
+
+	var registries []core.Registry
+	for _, name := range names {
+		registry, err := core.BuildRegistry(c, name)
+		if err != nil {
+			for _, registry := range registries {
+				_ = registry.Close()
+			}
+			return nil, err
+		}
+		registries = append(registries, registry)
+	}
+	return registries, nil
+}
+
+// included reports whether the record is of the types to include.
+func (z *zoneFlags) included(record *core.Record) bool {
+	if *z.types == "" {
+		return true
+	}
+	for _, typ := range strings.Split(*z.types, ",") {
+		if strings.EqualFold(strings.TrimSpace(typ), record.Type) {
+			return true
+		}
+	}
+	return false
+}
+
+// apexNames returns the origin and the owners of SOA records, which are zone apexes.
+func apexNames(origin string, records []*core.Record) map[string]bool {
+	apex := map[string]bool{origin: true}
+	for _, record := range records {
+		if record.Type == "SOA" {
+			apex[record.CanonicalName] = true
+		}
+	}
+	return apex
+}
+
+// withoutTTL returns the desired records, with TTL zeroed where the current records hold them of any TTL.
+func withoutTTL(current []*core.Record, desired []*core.Record) (records []*core.Record) {
+	for _, record := range desired {
+		r := *record
+		r.TTL = 0
+		if slices.ContainsFunc(current, func(c *core.Record) bool { return core.SameRecord(c, &r) }) {
+			record = &r
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// diffRecords returns the operations turning the records of the registry into the desired records, grouped by name.
+// With prune, records under the origin of the types to include but not desired are deleted,
+// except SOA and NS records of the zone apexes.
+// With ignoreTTL, records held of another TTL are not changed.
+func (z *zoneFlags) diffRecords(registry core.Registry, desired []*core.Record, prune bool, ignoreTTL bool) (operations []*core.Operation, _ error) {
+	origin := z.origin()
+
+	names := map[string][]*core.Record{}
+	for _, record := range desired {
+		names[record.CanonicalName] = append(names[record.CanonicalName], record)
+	}
+	apex := map[string]bool{}
+	if prune {
+		current, err := registry.ListRecords(origin)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range current {
+			if _, exist := names[record.CanonicalName]; !exist {
+				names[record.CanonicalName] = nil
+			}
+		}
+		apex = apexNames(origin, current)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(names)) {
+		current, err := registry.ListRecords(name)
+		if err != nil {
+			return nil, fmt.Errorf("listing records with domain [%s] failed: %v", name, err)
+		}
+		current = slices.DeleteFunc(current, func(record *core.Record) bool {
+			return record.CanonicalName != name
+		})
+
+		want := names[name]
+		if ignoreTTL {
+			want = withoutTTL(current, want)
+		}
+		if prune {
+			// Records of the types not included and those the registry manages are kept as they are.
+			for _, record := range current {
+				if !z.included(record) || record.Type == "SOA" || record.Type == "NS" && apex[name] {
+					want = append(want, record)
+				}
+			}
+		}
+
+		subdomain := ""
+		if origin != "" && name != origin {
+			subdomain = strings.TrimSuffix(name, "."+origin)
+		}
+
+		operations = append(operations, core.Diff(origin, subdomain, current, want, prune)...)
+	}
+
+	return operations, nil
+}
+
+func _export(args []string) error {
+	f := flag.NewFlagSet("export", flag.ExitOnError)
+	var (
+		z            = newZoneFlags(f, "Zone origin. Empty to be all records.")
+		registryName = f.String("registry", "", "Registry name.")
+		output       = f.String("o", "", "Path to write zone file. Empty to be stdout.")
+	)
+	_ = f.Parse(args)
+
+	if *registryName == "" {
+		return fmt.Errorf("requires [registry]")
+	}
+
+	registries, err := z.build(*registryName)
+	if err != nil {
+		return err
+	}
+	registry := registries[0]
+	defer registry.Close()
+
+	origin := z.origin()
+
+	records, err := registry.ListRecords(origin)
+	if err != nil {
+		return err
+	}
+	records = slices.DeleteFunc(records, func(record *core.Record) bool {
+		return !z.included(record)
+	})
+	slices.SortFunc(records, core.CompareRecords)
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	err = zonefile.Write(w, origin, records)
+	if err != nil {
+		return err
+	}
+
+	if *output != "" {
+		fmt.Printf("Exported %d records.\n", len(records))
+	}
+	return nil
+}
+
+func _import(args []string) error {
+	f := flag.NewFlagSet("import", flag.ExitOnError)
+	var (
+		z            = newZoneFlags(f, "Zone origin. Empty to be $ORIGIN of the file.")
+		registryName = f.String("registry", "", "Registry name.")
+		dryRun       = f.Bool("dry-run", false, "Show the changes without applying them.")
+		apexNS       = f.Bool("include-apex-ns", false, "Import NS records of the zone apex, which registries usually manage.")
+	)
+	_ = f.Parse(args)
+
+	if *registryName == "" || f.NArg() != 1 {
+		return fmt.Errorf("requires [registry] and zone file")
+	}
+
+	file, err := os.Open(f.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	parsed, apex, err := zonefile.Parse(file, z.origin())
+	if err != nil {
+		return fmt.Errorf("%s: %v", f.Arg(0), err)
+	}
+
+	parsed = slices.DeleteFunc(parsed, func(record *core.Record) bool {
+		if !*apexNS && record.Type == "NS" && record.CanonicalName == apex {
+			return true
+		}
+		return !z.included(record)
+	})
+
+	registries, err := z.build(*registryName)
+	if err != nil {
+		return err
+	}
+	registry := registries[0]
+	defer registry.Close()
+
+	// Records in the registry but not in the file are kept.
+	operations, err := z.diffRecords(registry, parsed, false, false)
+	if err != nil {
+		return err
+	}
+
+	printPlan(operations)
+	if *dryRun || len(operations) == 0 {
+		return nil
+	}
+
+	err = core.ApplyBatch(registry, core.NewBatch(operations))
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Imported.")
+	return nil
+}
@@ -0,0 +1,169 @@
+// Copyright 2025 Jelly Terra <jellyterra@proton.me>
+// This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0
+// that can be found in the LICENSE file and https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var (
+	ErrRolledBack = errors.New("rolled back")
+	// ErrPartialBatch is wrapped by the failures of native batches that might have been applied in part.
+	ErrPartialBatch = errors.New("batch partially applied")
+)
+
+// Batch is a set of changes applied as one unit.
+type Batch struct {
+	// DeleteAll are the names whose records are all deleted.
+	DeleteAll []string
+	Delete    []*Record
+	Append    []*Record
+}
+
+// BatchRegistry is implemented by registries with a native batch or transaction API.
+// A failed batch leaves the registry unchanged, unless the error wraps ErrPartialBatch.
+type BatchRegistry interface {
+	ApplyBatch(batch *Batch) error
+}
+
+// NewBatch collects the changes of the operations of a registry.
+func NewBatch(operations []*Operation) *Batch {
+	batch := &Batch{}
+	for _, op := range operations {
+		switch op.Op {
+		case OP_UPDATE:
+			if !slices.Contains(batch.DeleteAll, op.CanonicalName) {
+				batch.DeleteAll = append(batch.DeleteAll, op.CanonicalName)
+			}
+			batch.Append = append(batch.Append, &op.Record)
+		case OP_APPEND:
+			batch.Append = append(batch.Append, &op.Record)
+		case OP_DELETE:
+			batch.Delete = append(batch.Delete, &op.Record)
+		}
+	}
+	return batch
+}
+
+// ApplyBatch applies the batch natively where supported, or call by call otherwise.
+func ApplyBatch(registry Registry, batch *Batch) error {
+	if b, ok := registry.(BatchRegistry); ok {
+		return b.ApplyBatch(batch)
+	}
+	return applySequentially(registry, batch)
+}
+
+// applySequentially applies the batch call by call, stopping at the first failure.
+// Deletions go first, so a record deleted and appended again with another TTL is kept.
+func applySequentially(registry Registry, batch *Batch) error {
+	for _, name := range batch.DeleteAll {
+		err := registry.DeleteAllRecordsWithDomain(name)
+		if err != nil {
+			return fmt.Errorf("deleting all records with domain [%s] failed: %v", name, err)
+		}
+	}
+	for _, record := range batch.Delete {
+		err := registry.DeleteRecord(record)
+		if err != nil {
+			return fmt.Errorf("deleting record [%s %s] failed: %v", record.CanonicalName, record.Value, err)
+		}
+	}
+	for _, record := range batch.Append {
+		err := registry.AppendRecord(record)
+		if err != nil {
+			return fmt.Errorf("appending record [%s %s] failed: %v", record.CanonicalName, record.Value, err)
+		}
+	}
+	return nil
+}
+
+// ExecuteAtomic applies the operations as one unit.
+// Each registry applies its share natively in a batch where supported, or call by call otherwise.
+// If any registry fails, the names touched in all registries are restored to their prior records,
+// every operation is reported with the failure, and ErrRolledBack is returned.
+func ExecuteAtomic(c *Context, roleDef *RoleDef, operations []*Operation, callback func(err error, op *Operation)) error {
+	// Prior records are restored on failure.
+	e, err := prepare(c, roleDef, operations, true, callback)
+	if err != nil {
+		return err
+	}
+	defer e.Close()
+
+	var (
+		order   []string
+		grouped = map[string][]*Operation{}
+		touched = map[string]map[string][]*Record{}
+	)
+	for _, op := range e.operations {
+		if _, exist := grouped[op.Registry]; !exist {
+			touched[op.Registry] = map[string][]*Record{}
+			order = append(order, op.Registry)
+		}
+		grouped[op.Registry] = append(grouped[op.Registry], op)
+		touched[op.Registry][op.CanonicalName] = op.Prior
+	}
+
+	var (
+		applied []string
+		cause   error
+	)
+	for _, registryName := range order {
+		registry := e.registries[registryName]
+
+		err := ApplyBatch(registry, NewBatch(grouped[registryName]))
+
+		// A failed native batch changes nothing, while failed calls might have applied part of the changes.
+		if _, native := registry.(BatchRegistry); err == nil || !native || errors.Is(err, ErrPartialBatch) {
+			applied = append(applied, registryName)
+		}
+		if err != nil {
+			cause = fmt.Errorf("registry [%s]: %v", registryName, err)
+			break
+		}
+	}
+
+	if cause == nil {
+		for _, op := range e.operations {
+			e.callback(nil, op)
+		}
+		return nil
+	}
+
+	// Compensate by restoring the prior records.
+
+	var restoreErrs []error
+	for _, registryName := range applied {
+		// The registry might have cached the state before the changes, so build a fresh one.
+		registry, err := BuildRegistry(c, registryName)
+		if err != nil {
+			restoreErrs = append(restoreErrs, err)
+			continue
+		}
+
+		batch := &Batch{}
+		for name, prior := range touched[registryName] {
+			batch.DeleteAll = append(batch.DeleteAll, name)
+			batch.Append = append(batch.Append, prior...)
+		}
+
+		err = ApplyBatch(registry, batch)
+		if err != nil {
+			restoreErrs = append(restoreErrs, fmt.Errorf("restoring registry [%s] failed: %v", registryName, err))
+		}
+		_ = registry.Close()
+	}
+
+	err = fmt.Errorf("%w: %v", ErrRolledBack, cause)
+	if len(restoreErrs) != 0 {
+		err = fmt.Errorf("%v, and rolling back failed: %w", err, errors.Join(restoreErrs...))
+	}
+
+	for _, op := range e.operations {
+		e.callback(err, op)
+	}
+	return err
+}
@@ -0,0 +1,124 @@
+// Copyright 2025 Jelly Terra <jellyterra@proton.me>
+// This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0
+// that can be found in the LICENSE file and https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+type AuditEntry struct {
+	Time      int64     `json:"time"`
+	Role      string    `json:"role"`
+	KeyID     string    `json:"key_id"`
+	SourceIP  string    `json:"source_ip"`
+	RequestID string    `json:"request_id"`
+	Registry  string    `json:"registry"`
+	Op        string    `json:"op"`
+	Name      string    `json:"name"`
+	Prior     []*Record `json:"prior"`
+	New       []*Record `json:"new"`
+	Result    string    `json:"result"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// KeyID identifies the key in logs without revealing it.
+func KeyID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:6])
+}
+
+// AuditLog appends entries in JSONL to the file, rotating it to path.1, path.2, ... when it exceeds the max size.
+type AuditLog struct {
+	Path       string
+	MaxSize    int64
+	MaxBackups int
+
+	f    *os.File
+	size int64
+	lock sync.Mutex
+}
+
+func OpenAuditLog(path string, maxSize int64, maxBackups int) (*AuditLog, error) {
+	if maxBackups < 1 {
+		return nil, fmt.Errorf("audit log requires at least one backup")
+	}
+
+	l := &AuditLog{Path: path, MaxSize: maxSize, MaxBackups: maxBackups}
+	return l, l.open()
+}
+
+func (l *AuditLog) open() error {
+	f, err := os.OpenFile(l.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+
+	stat, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	l.f, l.size = f, stat.Size()
+	return nil
+}
+
+// RotatedAuditLogPath returns the path of the nth backup, or the current file for zero.
+func RotatedAuditLogPath(path string, n int) string {
+	if n == 0 {
+		return path
+	}
+	return fmt.Sprintf("%s.%d", path, n)
+}
+
+func (l *AuditLog) rotate() error {
+	err := l.f.Close()
+	if err != nil {
+		return err
+	}
+
+	_ = os.Remove(RotatedAuditLogPath(l.Path, l.MaxBackups))
+	for n := l.MaxBackups - 1; n >= 0; n-- {
+		err = os.Rename(RotatedAuditLogPath(l.Path, n), RotatedAuditLogPath(l.Path, n+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return l.open()
+}
+
+func (l *AuditLog) Write(entry *AuditEntry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.MaxSize > 0 && l.size > 0 && l.size+int64(len(b)) > l.MaxSize {
+		err = l.rotate()
+		if err != nil {
+			return err
+		}
+	}
+
+	n, err := l.f.Write(b)
+	l.size += int64(n)
+	return err
+}
+
+func (l *AuditLog) Close() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.f.Close()
+}
@@ -5,18 +5,22 @@
 package core
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
-	"os"
-	"path"
 	"regexp"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 )
 
+var ErrPermissionDenied = errors.New("permission denied")
+
 type RegistryDef struct {
+	Version int `json:"version,omitempty"`
+
 	Builder       string            `json:"builder"`
 	BuilderParams map[string]string `json:"builder_params"`
 }
@@ -32,24 +36,67 @@ type AuthKeyDef struct {
 }
 
 type RoleDef struct {
+	Version int `json:"version,omitempty"`
+
 	Keys           map[string]AuthKeyDef       `json:"keys"`
 	ManagedDomains map[string]ManagedDomainDef `json:"managed_domains"`
+
+	// Admin is allowed to use administrative APIs across all roles.
+	Admin bool `json:"admin,omitempty"`
+}
+
+// WebhookDef receives changes of the roles and registries, or of all if both are empty.
+type WebhookDef struct {
+	Version int `json:"version,omitempty"`
+
+	URL string `json:"url"`
+
+	// Secret signs the payloads. It can be a reference resolved by ResolveSecret on each delivery.
+	Secret string `json:"secret"`
+
+	Roles      []string `json:"roles"`
+	Registries []string `json:"registries"`
+}
+
+func (w *WebhookDef) Match(role string, registry string) bool {
+	return (len(w.Roles) == 0 || slices.Contains(w.Roles, role)) &&
+		(len(w.Registries) == 0 || slices.Contains(w.Registries, registry))
 }
 
 type ContextCache struct {
-	Time     int64
+	Revision int64
 	Val      any
 	lastUsed atomic.Int64
 }
 
 type Context struct {
-	BaseDir string
+	// Store keeps the config documents, under the config directory for the builtin stores.
+	Store Store
 
 	CacheLifetime int64
 	lastCheck     atomic.Int64
 
 	Cache     map[string]*ContextCache
 	cacheLock sync.RWMutex
+
+	// notified is set while the store notifies changes, so the cache is trusted without checking revisions.
+	notified atomic.Bool
+	// generation counts invalidations, so loads racing with them are not cached.
+	generation atomic.Int64
+
+	// OnReload is called with the config document that has been reloaded on change. Nil to be ignored.
+	OnReload func(kind string, name string)
+
+	// CollectPrior lists the records of the names before ExecuteAll changes them, as Operation.Prior for the audit log.
+	CollectPrior bool
+
+	// Limits of ExecuteAll. Zero to be unlimited.
+	MaxOperations             int
+	MaxConcurrency            int
+	MaxConcurrencyPerRegistry int
+
+	registrySlots     map[string]chan struct{}
+	registrySlotsLock sync.Mutex
 }
 
 func (c *Context) purgeCache() {
@@ -74,7 +121,12 @@ func (c *Context) purgeCache() {
 			continue
 		}
 
-		_, err := os.Stat(k)
+		if c.notified.Load() {
+			continue
+		}
+
+		kind, name, _ := strings.Cut(k, "/")
+		_, err := c.Store.Revision(kind, name)
 		if err != nil {
 			// Deleted.
 			delete(c.Cache, k)
@@ -82,21 +134,83 @@ func (c *Context) purgeCache() {
 	}
 }
 
-func Query[T any](c *Context, v *T, keys ...string) (*T, error) {
-	c.purgeCache()
+// List returns the names of config of the kind, e.g. role or registry.
+func List(c *Context, kind string) ([]string, error) {
+	return c.Store.List(kind)
+}
+
+// invalidate drops the cache of the document changed, and calls OnReload whether it has been cached or not,
+// as new documents are picked up as well.
+func (c *Context) invalidate(kind string, name string) {
+	c.cacheLock.Lock()
+	c.generation.Add(1)
+	delete(c.Cache, kind+"/"+name)
+	delete(c.Cache, kind+"/")
+	c.cacheLock.Unlock()
+
+	if c.OnReload != nil {
+		c.OnReload(kind, name)
+	}
+}
 
-	fName := path.Join(keys...) + ".json"
-	if strings.Contains(fName, "..") {
-		return nil, errors.New("invalid fName")
+// Watch drops the cache of documents changed in the store and calls OnReload for them, until the context is done.
+// While the store notifies changes, lookups trust the cache. Otherwise, the store is polled and lookups check revisions.
+func (c *Context) Watch(ctx context.Context) (notified bool, _ error) {
+	err := c.Store.Watch(ctx, c.invalidate)
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrWatchUnsupported):
+		go func() { _ = pollStore(ctx, c.Store, c.invalidate) }()
+		return false, nil
+	default:
+		return false, err
 	}
 
-	fStat, err := os.Stat(fName)
+	// Drop what has been cached before watching.
+	c.cacheLock.Lock()
+	clear(c.Cache)
+	c.notified.Store(true)
+	c.cacheLock.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		c.notified.Store(false)
+	}()
+
+	return true, nil
+}
+
+func Query[T any](c *Context, v *T, kind string, name string) (*T, error) {
+	c.purgeCache()
+
+	err := validateNames(kind, name)
 	if err != nil {
 		return nil, err
 	}
+	key := kind + "/" + name
+
+	c.cacheLock.RLock()
+	cache, exist := c.Cache[key]
+	generation := c.generation.Load()
+	c.cacheLock.RUnlock()
+
+	if exist && c.notified.Load() {
+		// Cache hit. Changes would have dropped it.
+		metricCache.Inc("hit")
+		cache.lastUsed.Store(time.Now().Unix())
+		return cache.Val.(*T), nil
+	}
+
+	var revision int64
+	if !c.notified.Load() {
+		revision, err = c.Store.Revision(kind, name)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	cacheMiss := func() (*T, error) {
-		b, err := os.ReadFile(fName)
+		b, err := c.Store.Get(kind, name)
 		if err != nil {
 			return nil, err
 		}
@@ -106,33 +220,35 @@ func Query[T any](c *Context, v *T, keys ...string) (*T, error) {
 			return nil, err
 		}
 
-		// Write to cache.
+		// Write to cache, unless it has been changed meanwhile.
 		c.cacheLock.Lock()
-		cache := &ContextCache{Time: fStat.ModTime().Unix(), Val: v}
-		cache.lastUsed.Store(time.Now().Unix())
-		c.Cache[fName] = cache
+		if c.generation.Load() == generation {
+			cache := &ContextCache{Revision: revision, Val: v}
+			cache.lastUsed.Store(time.Now().Unix())
+			c.Cache[key] = cache
+		}
 		c.cacheLock.Unlock()
 
 		return v, nil
 	}
 
-	c.cacheLock.RLock()
-	cache, exist := c.Cache[fName]
-	c.cacheLock.RUnlock()
 	if exist {
 		// Cache miss.
-		if fStat.ModTime().Unix() != cache.Time {
-			// Disk change.
+		if revision != cache.Revision {
+			// Store change.
+			metricCache.Inc("miss")
 			v, err = cacheMiss()
 			if err != nil {
 				return nil, err
 			}
 		} else {
 			// Cache hit.
+			metricCache.Inc("hit")
 			cache.lastUsed.Store(time.Now().Unix())
 			v = cache.Val.(*T)
 		}
 	} else {
+		metricCache.Inc("miss")
 		v, err = cacheMiss()
 		if err != nil {
 			return nil, err
@@ -142,6 +258,44 @@ func Query[T any](c *Context, v *T, keys ...string) (*T, error) {
 	return v, err
 }
 
+// QueryNames returns the names of config of the kind like List, cached while the store notifies changes.
+func QueryNames(c *Context, kind string) ([]string, error) {
+	if !c.notified.Load() {
+		return List(c, kind)
+	}
+	c.purgeCache()
+
+	// The key is no document, as names are never empty.
+	key := kind + "/"
+
+	c.cacheLock.RLock()
+	cache, exist := c.Cache[key]
+	generation := c.generation.Load()
+	c.cacheLock.RUnlock()
+
+	if exist {
+		metricCache.Inc("hit")
+		cache.lastUsed.Store(time.Now().Unix())
+		return cache.Val.([]string), nil
+	}
+
+	metricCache.Inc("miss")
+	names, err := List(c, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	c.cacheLock.Lock()
+	if c.generation.Load() == generation {
+		cache := &ContextCache{Val: names}
+		cache.lastUsed.Store(time.Now().Unix())
+		c.Cache[key] = cache
+	}
+	c.cacheLock.Unlock()
+
+	return names, nil
+}
+
 type ValidationResult struct {
 	Registry string
 }
@@ -149,13 +303,13 @@ type ValidationResult struct {
 func Validate(roleDef *RoleDef, domain string, subdomain string) (*ValidationResult, error) {
 	d, exist := roleDef.ManagedDomains[domain]
 	if !exist {
-		return nil, errors.New("permission denied")
+		return nil, ErrPermissionDenied
 	}
 
 	switch d.Glob {
 	case "":
 		if subdomain != "" {
-			return nil, errors.New("permission denied")
+			return nil, ErrPermissionDenied
 		}
 	case "*":
 	default:
@@ -164,7 +318,7 @@ func Validate(roleDef *RoleDef, domain string, subdomain string) (*ValidationRes
 			return nil, err
 		}
 		if !matched {
-			return nil, errors.New("permission denied")
+			return nil, ErrPermissionDenied
 		}
 	}
 
@@ -172,3 +326,25 @@ func Validate(roleDef *RoleDef, domain string, subdomain string) (*ValidationRes
 		Registry: d.Registry,
 	}, nil
 }
+
+// SplitName splits the fully qualified name into the longest domain managed by the role and the subdomain under it.
+func SplitName(roleDef *RoleDef, name string) (domain string, subdomain string, err error) {
+	name = strings.TrimSuffix(name, ".")
+
+	for d := range roleDef.ManagedDomains {
+		if len(d) <= len(domain) {
+			continue
+		}
+		switch {
+		case name == d:
+			domain, subdomain = d, ""
+		case strings.HasSuffix(name, "."+d):
+			domain, subdomain = d, strings.TrimSuffix(name, "."+d)
+		}
+	}
+	if domain == "" {
+		return "", "", ErrPermissionDenied
+	}
+
+	return domain, subdomain, nil
+}
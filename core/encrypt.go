@@ -0,0 +1,178 @@
+// Copyright 2025 Jelly Terra <jellyterra@proton.me>
+// This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0
+// that can be found in the LICENSE file and https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+// encryptedPrefix marks documents sealed by NaCl secretbox, followed by base64 of nonce and box.
+const encryptedPrefix = "autodns:secretbox:"
+
+var ErrDecryption = errors.New("decryption failed")
+
+// ParseMasterKey parses the base64 of 32 bytes.
+func ParseMasterKey(s string) (*[32]byte, error) {
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("master key: %v", err)
+	}
+	if len(b) != 32 {
+		return nil, errors.New("master key: requires 32 bytes")
+	}
+	return (*[32]byte)(b), nil
+}
+
+// GenerateMasterKey returns a new master key in base64.
+func GenerateMasterKey() string {
+	var key [32]byte
+	_, _ = rand.Read(key[:])
+	return base64.StdEncoding.EncodeToString(key[:])
+}
+
+// EncryptedStore encrypts documents at rest by the master key.
+// Documents still in plaintext are read as they are, and encrypted once written.
+type EncryptedStore struct {
+	Store
+	Key *[32]byte
+
+	// PreviousKey also opens documents while rotating from it, so an interrupted rotation can be run again.
+	PreviousKey *[32]byte
+}
+
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(encryptedPrefix))
+}
+
+func (s *EncryptedStore) Get(kind string, name string) ([]byte, error) {
+	data, err := s.Store.Get(kind, name)
+	if err != nil {
+		return nil, err
+	}
+	return s.open(kind, name, data)
+}
+
+func (s *EncryptedStore) Put(kind string, name string, data []byte) error {
+	sealed, err := s.seal(data)
+	if err != nil {
+		return err
+	}
+	return s.Store.Put(kind, name, sealed)
+}
+
+// open decrypts the document by the key or the previous key, or returns it as it is in plaintext.
+func (s *EncryptedStore) open(kind string, name string, data []byte) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return data, nil
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(string(data[len(encryptedPrefix):]))
+	if err != nil || len(sealed) < 24 {
+		return nil, fmt.Errorf("%s/%s: %w", kind, name, ErrDecryption)
+	}
+
+	var nonce [24]byte
+	copy(nonce[:], sealed)
+	for _, key := range []*[32]byte{s.Key, s.PreviousKey} {
+		if key == nil {
+			continue
+		}
+		data, ok := secretbox.Open(nil, sealed[24:], &nonce, key)
+		if ok {
+			return data, nil
+		}
+	}
+	return nil, fmt.Errorf("%s/%s: %w", kind, name, ErrDecryption)
+}
+
+func (s *EncryptedStore) seal(data []byte) ([]byte, error) {
+	var nonce [24]byte
+	_, err := rand.Read(nonce[:])
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := secretbox.Seal(nonce[:], data, &nonce, s.Key)
+	return []byte(encryptedPrefix + base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+// Reencrypt rewrites every document of the store by its key, including the backups kept by FSStore.
+// Documents under the previous key or in plaintext are read as well, so it can be run again after an interruption.
+func Reencrypt(s *EncryptedStore) (n int, _ error) {
+	fsStore, ok := s.Store.(*FSStore)
+
+	// On FSStore, the first write moves the current version to the backup, which the second write replaces by the encrypted one.
+	writes := 1
+	if ok {
+		writes = 2
+	}
+
+	for _, kind := range Kinds {
+		names, err := s.List(kind)
+		if err != nil {
+			return n, err
+		}
+		for _, name := range names {
+			data, err := s.Get(kind, name)
+			if err != nil {
+				return n, err
+			}
+			for range writes {
+				err = s.Put(kind, name, data)
+				if err != nil {
+					return n, err
+				}
+			}
+			n++
+		}
+	}
+
+	if !ok {
+		return n, nil
+	}
+
+	// Backups of deleted documents are left alone by the writes above.
+	for _, kind := range Kinds {
+		backups, err := fsStore.orphanBackups(kind)
+		if err != nil {
+			return n, err
+		}
+		for _, p := range backups {
+			raw, err := os.ReadFile(p)
+			if err != nil {
+				return n, err
+			}
+			if !IsEncrypted(raw) {
+				raw, err = ToJSON(strings.TrimSuffix(p, ".bak"), raw)
+				if err != nil {
+					return n, err
+				}
+			}
+
+			data, err := s.open(kind, path.Base(p), raw)
+			if err != nil {
+				return n, err
+			}
+			sealed, err := s.seal(data)
+			if err != nil {
+				return n, err
+			}
+			err = WriteFileAtomic(p, sealed, 0600, false)
+			if err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,72 @@
+// Copyright 2025 Jelly Terra <jellyterra@proton.me>
+// This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0
+// that can be found in the LICENSE file and https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func testKey(t *testing.T) *[32]byte {
+	key, err := ParseMasterKey(GenerateMasterKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestEncryptedStoreFormats(t *testing.T) {
+	for _, ext := range []string{".yaml", ".toml"} {
+		t.Run(ext, func(t *testing.T) {
+			dir := t.TempDir()
+			err := os.Mkdir(path.Join(dir, "role"), 0700)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			plain := map[string]string{
+				".yaml": "managed_domains:\n  jellyterra.com: {registry: jellyterra.com, glob: \"*\"}\n",
+				".toml": "[managed_domains.\"jellyterra.com\"]\nregistry = \"jellyterra.com\"\nglob = \"*\"\n",
+			}[ext]
+			p := path.Join(dir, "role", "jellyterra"+ext)
+			err = os.WriteFile(p, []byte(plain), 0600)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			s := &EncryptedStore{Store: &FSStore{Dir: dir}, Key: testKey(t)}
+
+			n, err := Reencrypt(s)
+			if err != nil || n != 1 {
+				t.Fatalf("Reencrypt() = %d, %v", n, err)
+			}
+
+			for _, f := range []string{p, p + ".bak"} {
+				b, err := os.ReadFile(f)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !IsEncrypted(b) {
+					t.Errorf("%s is not encrypted: %q", path.Base(f), b)
+				}
+			}
+
+			roleDef, err := Load(s, &RoleDef{}, "role", "jellyterra")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d := roleDef.ManagedDomains["jellyterra.com"]; d.Registry != "jellyterra.com" || d.Glob != "*" {
+				t.Errorf("managed domain = %+v", d)
+			}
+
+			// Plain stores see the encrypted document as it is.
+			b, err := s.Store.Get("role", "jellyterra")
+			if err != nil || !IsEncrypted(b) {
+				t.Errorf("Get() without key = %q, %v", b, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2025 Jelly Terra <jellyterra@proton.me>
+// This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0
+// that can be found in the LICENSE file and https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+)
+
+// WriteFileAtomic replaces the file by a synced temporary file, so readers see either the old or the new content.
+// With backup, the previous content is kept in <path>.bak.
+func WriteFileAtomic(p string, data []byte, perm os.FileMode, backup bool) error {
+	if backup {
+		prev, err := os.ReadFile(p)
+		switch {
+		case err == nil:
+			err = WriteFileAtomic(p+".bak", prev, perm, false)
+			if err != nil {
+				return err
+			}
+		case errors.Is(err, fs.ErrNotExist):
+		default:
+			return err
+		}
+	}
+
+	f, err := os.CreateTemp(path.Dir(p), "."+path.Base(p)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Chmod(perm)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cErr := f.Close(); err == nil {
+		err = cErr
+	}
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(f.Name(), p)
+	if err != nil {
+		return err
+	}
+
+	// Persist the rename.
+	dir, err := os.Open(path.Dir(p))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
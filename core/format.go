@@ -0,0 +1,107 @@
+// Copyright 2025 Jelly Terra <jellyterra@proton.me>
+// This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0
+// that can be found in the LICENSE file and https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigExts are the extensions of config files in the order of precedence.
+var ConfigExts = []string{".json", ".yaml", ".yml", ".toml"}
+
+// cutConfigExt returns the name of the config file without its extension.
+func cutConfigExt(fName string) (string, bool) {
+	for _, ext := range ConfigExts {
+		name, ok := strings.CutSuffix(fName, ext)
+		if ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// ToJSON converts the content of the config file to JSON by its extension.
+func ToJSON(p string, data []byte) ([]byte, error) {
+	var v any
+	switch path.Ext(p) {
+	case ".json":
+		return data, nil
+	case ".yaml", ".yml":
+		err := yaml.Unmarshal(data, &v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", p, err)
+		}
+	case ".toml":
+		err := toml.Unmarshal(data, &v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", p, err)
+		}
+	default:
+		return nil, fmt.Errorf("%s: unknown config format", p)
+	}
+	return json.Marshal(v)
+}
+
+// FromJSON converts the JSON to the format of the config file by its extension.
+// Comments in YAML and TOML are not kept.
+func FromJSON(p string, data []byte) ([]byte, error) {
+	ext := path.Ext(p)
+	if ext == ".json" {
+		return data, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var v any
+	err := decoder.Decode(&v)
+	if err != nil {
+		return nil, err
+	}
+	v = plainNumbers(v)
+
+	switch ext {
+	case ".yaml", ".yml":
+		return yaml.Marshal(v)
+	case ".toml":
+		var buf bytes.Buffer
+		err = toml.NewEncoder(&buf).Encode(v)
+		return buf.Bytes(), err
+	default:
+		return nil, fmt.Errorf("%s: unknown config format", p)
+	}
+}
+
+// plainNumbers replaces JSON numbers by integers where exact, so they are not encoded as strings or floats, and drops nulls.
+func plainNumbers(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			if e == nil {
+				// TOML has no null, and absence decodes the same.
+				delete(v, k)
+				continue
+			}
+			v[k] = plainNumbers(e)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = plainNumbers(e)
+		}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return v
+}
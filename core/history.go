@@ -0,0 +1,165 @@
+// Copyright 2025 Jelly Terra <jellyterra@proton.me>
+// This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0
+// that can be found in the LICENSE file and https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+)
+
+const OP_ROLLBACK = "rollback"
+
+// ReadAuditLog reads the audit log and its rotated backups, from the oldest to the newest.
+func ReadAuditLog(path string) (entries []*AuditEntry, _ error) {
+	var paths []string
+	for n := 0; ; n++ {
+		p := RotatedAuditLogPath(path, n)
+		_, err := os.Stat(p)
+		if err != nil {
+			if os.IsNotExist(err) && n != 0 {
+				break
+			}
+			return nil, err
+		}
+		paths = append(paths, p)
+	}
+	slices.Reverse(paths)
+
+	for _, p := range paths {
+		err := func() error {
+			f, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			scanner := bufio.NewScanner(f)
+			scanner.Buffer(nil, 1<<20)
+			for line := 1; scanner.Scan(); line++ {
+				entry := &AuditEntry{}
+				err = json.Unmarshal(scanner.Bytes(), entry)
+				if err != nil {
+					return fmt.Errorf("%s:%d: %v", p, line, err)
+				}
+				entries = append(entries, entry)
+			}
+			return scanner.Err()
+		}()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return entries, nil
+}
+
+// AuditFilter selects entries by the non-empty fields.
+type AuditFilter struct {
+	Name      string `json:"name"`
+	Role      string `json:"role"`
+	RequestID string `json:"request_id"`
+	Since     int64  `json:"since"`
+}
+
+func (f *AuditFilter) Empty() bool {
+	return f.Name == "" && f.Role == "" && f.RequestID == "" && f.Since == 0
+}
+
+func (f *AuditFilter) Match(entry *AuditEntry) bool {
+	return (f.Name == "" || f.Name == entry.Name) &&
+		(f.Role == "" || f.Role == entry.Role) &&
+		(f.RequestID == "" || f.RequestID == entry.RequestID) &&
+		entry.Time >= f.Since
+}
+
+func FilterAuditEntries(entries []*AuditEntry, filter *AuditFilter) (matched []*AuditEntry) {
+	for _, entry := range entries {
+		if filter.Match(entry) {
+			matched = append(matched, entry)
+		}
+	}
+	return matched
+}
+
+type RollbackResult struct {
+	Registry string    `json:"registry"`
+	Name     string    `json:"name"`
+	Records  []*Record `json:"records"`
+	Error    string    `json:"error,omitempty"`
+
+	// Prior are the records replaced by the rollback.
+	Prior []*Record `json:"-"`
+}
+
+// Rollback restores each name touched by the successful entries to the records it had before the earliest of them.
+func Rollback(c *Context, entries []*AuditEntry) ([]*RollbackResult, error) {
+	var (
+		results []*RollbackResult
+		seen    = map[[2]string]bool{}
+	)
+	for _, entry := range entries {
+		key := [2]string{entry.Registry, entry.Name}
+		if entry.Result != "success" || seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		results = append(results, &RollbackResult{
+			Registry: entry.Registry,
+			Name:     entry.Name,
+			Records:  entry.Prior,
+		})
+	}
+	if len(results) == 0 {
+		return nil, errors.New("nothing to roll back")
+	}
+
+	registries := map[string]Registry{}
+	defer func() {
+		for _, registry := range registries {
+			_ = registry.Close()
+		}
+	}()
+
+	for _, result := range results {
+		registry, exist := registries[result.Registry]
+		if !exist {
+			var err error
+			registry, err = BuildRegistry(c, result.Registry)
+			if err != nil {
+				return nil, err
+			}
+			registries[result.Registry] = registry
+		}
+
+		err := RestoreRecords(registry, result)
+		if err != nil {
+			result.Error = err.Error()
+		}
+	}
+
+	return results, nil
+}
+
+// RestoreRecords replaces all records with the name by the records of the result.
+func RestoreRecords(registry Registry, result *RollbackResult) error {
+	records, err := registry.ListRecords(result.Name)
+	if err != nil {
+		return err
+	}
+	result.Prior = slices.DeleteFunc(records, func(record *Record) bool {
+		return record.CanonicalName != result.Name
+	})
+
+	// Registries with a native batch restore the name at once.
+	return ApplyBatch(registry, &Batch{
+		DeleteAll: []string{result.Name},
+		Append:    result.Records,
+	})
+}
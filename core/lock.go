@@ -0,0 +1,12 @@
+// Copyright 2025 Jelly Terra <jellyterra@proton.me>
+// This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0
+// that can be found in the LICENSE file and https://mozilla.org/MPL/2.0/.
+
+//go:build !unix
+
+package core
+
+// LockFile is a no-op where advisory locks are not supported.
+func LockFile(p string) (unlock func() error, _ error) {
+	return func() error { return nil }, nil
+}
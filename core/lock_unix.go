@@ -0,0 +1,31 @@
+// Copyright 2025 Jelly Terra <jellyterra@proton.me>
+// This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0
+// that can be found in the LICENSE file and https://mozilla.org/MPL/2.0/.
+
+//go:build unix
+
+package core
+
+import (
+	"os"
+	"syscall"
+)
+
+// LockFile takes an exclusive advisory lock on the file, waiting for other holders.
+func LockFile(p string) (unlock func() error, _ error) {
+	f, err := os.OpenFile(p, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	return func() error {
+		defer f.Close()
+		return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+	}, nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2025 Jelly Terra <jellyterra@proton.me>
+// This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0
+// that can be found in the LICENSE file and https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"time"
+
+	"github.com/autodns/autodns.go/metrics"
+)
+
+var (
+	metricCache = metrics.NewCounterVec("autodns_config_cache_total",
+		"Config lookups in core.Query by result.", "result")
+	metricOperations = metrics.NewCounterVec("autodns_operations_total",
+		"Operations executed by type and result.", "op", "result")
+	metricRegistryCalls = metrics.NewHistogramVec("autodns_registry_call_duration_seconds",
+		"Latency of registry calls.", nil, "registry", "builder", "call")
+	metricRegistryErrors = metrics.NewCounterVec("autodns_registry_call_errors_total",
+		"Failed registry calls.", "registry", "builder", "call")
+)
+
+func resultLabel(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
+
+// instrumentedRegistry measures the calls to the underlying registry.
+type instrumentedRegistry struct {
+	Registry
+	name    string
+	builder string
+}
+
+func (r *instrumentedRegistry) observe(call string, start time.Time, err error) {
+	metricRegistryCalls.Since(start, r.name, r.builder, call)
+	if err != nil {
+		metricRegistryErrors.Inc(r.name, r.builder, call)
+	}
+}
+
+func (r *instrumentedRegistry) ListRecords(domain string) ([]*Record, error) {
+	start := time.Now()
+	records, err := r.Registry.ListRecords(domain)
+	r.observe("list_records", start, err)
+	return records, err
+}
+
+func (r *instrumentedRegistry) AppendRecord(record *Record) error {
+	start := time.Now()
+	err := r.Registry.AppendRecord(record)
+	r.observe("append_record", start, err)
+	return err
+}
+
+func (r *instrumentedRegistry) DeleteRecord(record *Record) error {
+	start := time.Now()
+	err := r.Registry.DeleteRecord(record)
+	r.observe("delete_record", start, err)
+	return err
+}
+
+func (r *instrumentedRegistry) DeleteAllRecordsWithDomain(domain string) error {
+	start := time.Now()
+	err := r.Registry.DeleteAllRecordsWithDomain(domain)
+	r.observe("delete_all_records_with_domain", start, err)
+	return err
+}
+
+// instrumentedBatchRegistry forwards BatchRegistry of the underlying registry.
+type instrumentedBatchRegistry struct {
+	*instrumentedRegistry
+}
+
+func (r *instrumentedBatchRegistry) ApplyBatch(batch *Batch) error {
+	start := time.Now()
+	err := r.Registry.(BatchRegistry).ApplyBatch(batch)
+	r.observe("apply_batch", start, err)
+	return err
+}
+
+// instrument wraps the registry, keeping the optional interfaces it implements.
+func instrument(registry Registry, name string, builder string) Registry {
+	r := &instrumentedRegistry{Registry: registry, name: name, builder: builder}
+	if _, ok := registry.(BatchRegistry); ok {
+		return &instrumentedBatchRegistry{r}
+	}
+	return r
+}
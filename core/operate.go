@@ -5,7 +5,10 @@
 package core
 
 import (
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"sync"
 
 	"golang.org/x/net/idna"
@@ -13,6 +16,7 @@ import (
 
 const (
 	OP_UPDATE = "update"
+	OP_APPEND = "append"
 	OP_DELETE = "delete"
 )
 
@@ -22,7 +26,10 @@ type Operation struct {
 	Domain    string `json:"domain"`
 	Subdomain string `json:"subdomain"`
 
-	Registry string
+	Registry string `json:"registry,omitempty"`
+
+	// Prior are the records with the same name before the operations are executed.
+	Prior []*Record `json:"-"`
 }
 
 func ValidateOperation(roleDef *RoleDef, op *Operation) error {
@@ -46,61 +53,251 @@ func ValidateOperation(roleDef *RoleDef, op *Operation) error {
 			return err
 		}
 
-		op.CanonicalName = op.Domain + "." + op.Subdomain
+		op.CanonicalName = op.Subdomain + "." + op.Domain
 	}
 
 	return nil
 }
 
-func ExecuteAll(c *Context, roleDef *RoleDef, operations []*Operation, callback func(err error, op *Operation)) error {
-
-	// Authorize and check.
-
+// ValidateOperations validates each operation against the delegations of the role.
+func ValidateOperations(roleDef *RoleDef, operations []*Operation) error {
 	for _, op := range operations {
 		err := ValidateOperation(roleDef, op)
 		if err != nil {
 			return err
 		}
 	}
+	return nil
+}
 
-	// Build registries.
+var ErrTooManyOperations = errors.New("too many operations")
+
+// CheckOperations rejects requests above the maximum operation count.
+func CheckOperations(c *Context, operations []*Operation) error {
+	if c.MaxOperations > 0 && len(operations) > c.MaxOperations {
+		return fmt.Errorf("%w: %d exceeds the maximum %d", ErrTooManyOperations, len(operations), c.MaxOperations)
+	}
+	return nil
+}
+
+// registrySlot returns the semaphore shared by all requests to the registry, or nil if unlimited.
+func (c *Context) registrySlot(name string) chan struct{} {
+	if c.MaxConcurrencyPerRegistry <= 0 {
+		return nil
+	}
+
+	c.registrySlotsLock.Lock()
+	defer c.registrySlotsLock.Unlock()
+
+	if c.registrySlots == nil {
+		c.registrySlots = map[string]chan struct{}{}
+	}
+	slot, exist := c.registrySlots[name]
+	if !exist {
+		slot = make(chan struct{}, c.MaxConcurrencyPerRegistry)
+		c.registrySlots[name] = slot
+	}
+	return slot
+}
+
+type operationKey struct {
+	Op       string
+	Registry string
+	Record   Record
+}
 
-	registries := make(map[string]Registry)
+// execution holds the operations validated and deduplicated, with their registries built and prior records collected.
+type execution struct {
+	operations []*Operation
+	registries map[string]Registry
+	callback   func(err error, op *Operation)
+}
 
+func (e *execution) Close() {
+	for _, registry := range e.registries {
+		_ = registry.Close()
+	}
+}
+
+// OP_REQUEST names the request as a whole, when it is rejected before its operations are looked at.
+const OP_REQUEST = "request"
+
+// RejectRequest reports the request once with the error, as for too many operations.
+func RejectRequest(err error, callback func(err error, op *Operation)) {
+	metricOperations.Inc(OP_REQUEST, resultLabel(err))
+	callback(err, &Operation{Op: OP_REQUEST})
+}
+
+// Reject reports every operation with the error of the request, so rejected attempts are observed as well.
+// Operations rejected before validation are named as requested.
+func Reject(operations []*Operation, err error, callback func(err error, op *Operation)) {
 	for _, op := range operations {
-		registryDef, err := Query(c, &RegistryDef{}, "registry", op.Registry)
-		if err != nil {
-			return err
+		if op.CanonicalName == "" {
+			op.CanonicalName = strings.Trim(op.Subdomain+"."+op.Domain, ".")
+		}
+		metricOperations.Inc(op.Op, resultLabel(err))
+		callback(err, op)
+	}
+}
+
+// prepare validates the operations and builds their registries, listing the prior records if collectPrior.
+// Rejected operations are reported through the callback before the error is returned.
+func prepare(c *Context, roleDef *RoleDef, operations []*Operation, collectPrior bool, callback func(err error, op *Operation)) (*execution, error) {
+	err := CheckOperations(c, operations)
+	if err != nil {
+		RejectRequest(err, callback)
+		return nil, err
+	}
+
+	// Authorize and check.
+
+	err = ValidateOperations(roleDef, operations)
+	if err != nil {
+		Reject(operations, err, callback)
+		return nil, err
+	}
+
+	// Drop identical operations. They are reported with the result of the first one.
+
+	requested := operations
+	var (
+		unique     []*Operation
+		firsts     = map[operationKey]*Operation{}
+		duplicates = map[*Operation][]*Operation{}
+	)
+	for _, op := range operations {
+		key := operationKey{Op: op.Op, Registry: op.Registry, Record: op.Record}
+		if first, exist := firsts[key]; exist {
+			duplicates[first] = append(duplicates[first], op)
+			continue
+		}
+		firsts[key] = op
+		unique = append(unique, op)
+	}
+	operations = unique
+
+	// Count the result of each operation.
+	notify := callback
+	callback = func(err error, op *Operation) {
+		metricOperations.Inc(op.Op, resultLabel(err))
+		notify(err, op)
+
+		for _, duplicate := range duplicates[op] {
+			duplicate.Prior = op.Prior
+			notify(err, duplicate)
 		}
+	}
+
+	// Build registries.
 
-		builder := RegistryBuilders[registryDef.Builder]
-		if builder == nil {
-			return fmt.Errorf("registry [%s] builder [%s] is not builtin", op.Registry, registryDef.Builder)
+	e := &execution{
+		operations: operations,
+		registries: map[string]Registry{},
+		callback:   callback,
+	}
+
+	for _, op := range operations {
+		if e.registries[op.Registry] != nil {
+			continue
 		}
 
-		registries[op.Registry], err = builder(registryDef.BuilderParams)
+		registry, err := BuildRegistry(c, op.Registry)
 		if err != nil {
-			return fmt.Errorf("registry [%s] builder [%s] failed: %v", op.Registry, registryDef.Builder, err)
+			e.Close()
+			Reject(requested, err, notify)
+			return nil, err
+		}
+		e.registries[op.Registry] = registry
+	}
+
+	if !collectPrior {
+		return e, nil
+	}
+
+	// Collect prior records.
+
+	prior := map[[2]string][]*Record{}
+
+	for _, op := range operations {
+		key := [2]string{op.Registry, op.CanonicalName}
+		if _, exist := prior[key]; !exist {
+			records, err := e.registries[op.Registry].ListRecords(op.CanonicalName)
+			if err != nil {
+				e.Close()
+				err = fmt.Errorf("listing records with domain [%s] failed: %v", op.CanonicalName, err)
+				Reject(requested, err, notify)
+				return nil, err
+			}
+			prior[key] = slices.DeleteFunc(records, func(record *Record) bool {
+				return record.CanonicalName != op.CanonicalName
+			})
 		}
+		op.Prior = prior[key]
 	}
 
+	return e, nil
+}
+
+func ExecuteAll(c *Context, roleDef *RoleDef, operations []*Operation, callback func(err error, op *Operation)) error {
+	e, err := prepare(c, roleDef, operations, c.CollectPrior, callback)
+	if err != nil {
+		return err
+	}
+	defer e.Close()
+
+	registries := e.registries
+	callback = e.callback
+
 	// Execute operations.
 
 	deleted := map[string][]*Operation{}
 	updated := map[string][]*Operation{}
+	appended := map[string][]*Operation{}
 
-	for _, op := range operations {
+	for _, op := range e.operations {
 		switch op.Op {
 		case OP_DELETE:
 			deleted[op.Registry] = append(deleted[op.Registry], op)
 		case OP_UPDATE:
 			updated[op.Registry] = append(updated[op.Registry], op)
+		case OP_APPEND:
+			appended[op.Registry] = append(appended[op.Registry], op)
 		}
 	}
 
+	var (
+		wg    sync.WaitGroup
+		slots chan struct{}
+	)
+	if c.MaxConcurrency > 0 {
+		slots = make(chan struct{}, c.MaxConcurrency)
+	}
+
+	// Run the call in background, waiting for a slot of the request and of the registry.
+	run := func(registryName string, call func()) {
+		if slots != nil {
+			slots <- struct{}{}
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if slots != nil {
+				defer func() { <-slots }()
+			}
+
+			registrySlot := c.registrySlot(registryName)
+			if registrySlot != nil {
+				registrySlot <- struct{}{}
+				defer func() { <-registrySlot }()
+			}
+
+			call()
+		}()
+	}
+
 	hasBeenDeleted := map[string]bool{}
 
-	var wg sync.WaitGroup
 	for registryName, operations := range updated {
 		for _, op := range operations {
 
@@ -110,35 +307,39 @@ func ExecuteAll(c *Context, roleDef *RoleDef, operations []*Operation, callback
 			}
 			hasBeenDeleted[op.CanonicalName] = true
 
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
+			run(registryName, func() {
 				err := registries[registryName].DeleteAllRecordsWithDomain(op.CanonicalName)
 				if err != nil {
 					callback(fmt.Errorf("deleting all records with domain [%s] failed: %v", op.Domain, err), op)
 				}
-			}()
+			})
 		}
 	}
 	wg.Wait()
 
-	for registryName, operations := range updated {
+	// Deleted before appending, so a record deleted and appended again with another TTL is kept.
+	for registryName, operations := range deleted {
 		for _, op := range operations {
-			go func() {
-				err := registries[registryName].AppendRecord(&op.Record)
+			run(registryName, func() {
+				err := registries[registryName].DeleteRecord(&op.Record)
 				callback(err, op)
-			}()
+			})
 		}
 	}
+	wg.Wait()
 
-	for registryName, operations := range deleted {
-		for _, op := range operations {
-			go func() {
-				err := registries[registryName].DeleteRecord(&op.Record)
-				callback(err, op)
-			}()
+	// Updated records replace the deleted ones, appended records are added alongside the existing ones.
+	for _, group := range []map[string][]*Operation{updated, appended} {
+		for registryName, operations := range group {
+			for _, op := range operations {
+				run(registryName, func() {
+					err := registries[registryName].AppendRecord(&op.Record)
+					callback(err, op)
+				})
+			}
 		}
 	}
+	wg.Wait()
 
 	return nil
 }
@@ -0,0 +1,142 @@
+// Copyright 2025 Jelly Terra <jellyterra@proton.me>
+// This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0
+// that can be found in the LICENSE file and https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+)
+
+// ManifestRecord is a desired record under a delegated domain.
+type ManifestRecord struct {
+	Subdomain string `json:"subdomain"`
+	Type      string `json:"type"`
+	Value     string `json:"value"`
+
+	// TTL is not compared if zero.
+	TTL int `json:"ttl"`
+}
+
+func recordKey(record *Record) [3]string {
+	return [3]string{record.CanonicalName, record.Type, record.Value}
+}
+
+// SameRecord reports whether the current record is as desired. TTL is not compared if zero in the desired.
+func SameRecord(current *Record, desired *Record) bool {
+	return recordKey(current) == recordKey(desired) && (desired.TTL == 0 || desired.TTL == current.TTL)
+}
+
+// Diff returns the operations turning the current records into the desired records of the same name.
+// Without prune, current records that are not desired are kept.
+// Records whose TTL changes are deleted and appended again, which executes deletions first.
+func Diff(domain string, subdomain string, current []*Record, desired []*Record, prune bool) (operations []*Operation) {
+	op := func(opName string, record *Record) *Operation {
+		return &Operation{Record: *record, Op: opName, Domain: domain, Subdomain: subdomain}
+	}
+
+	currentMap := map[[3]string]*Record{}
+	for _, record := range current {
+		currentMap[recordKey(record)] = record
+	}
+	desiredMap := map[[3]string]*Record{}
+	for _, record := range desired {
+		desiredMap[recordKey(record)] = record
+	}
+
+	for k, record := range desiredMap {
+		c, exist := currentMap[k]
+		switch {
+		case !exist:
+			operations = append(operations, op(OP_APPEND, record))
+		case !SameRecord(c, record):
+			operations = append(operations, op(OP_DELETE, c), op(OP_APPEND, record))
+		}
+	}
+	if prune {
+		for k, record := range currentMap {
+			if _, exist := desiredMap[k]; !exist {
+				operations = append(operations, op(OP_DELETE, record))
+			}
+		}
+	}
+
+	// Sorted by record, and deletions before appends of the same record.
+	slices.SortFunc(operations, func(a, b *Operation) int {
+		return cmp.Or(
+			CompareRecords(&a.Record, &b.Record),
+			cmp.Compare(opOrder(a.Op), opOrder(b.Op)),
+		)
+	})
+	return operations
+}
+
+func opOrder(opName string) int {
+	if opName == OP_DELETE {
+		return 0
+	}
+	return 1
+}
+
+// Plan returns the operations turning the records of the delegated domains into the desired records.
+// With prune, records under the domains visible to the role but not listed are deleted.
+func Plan(c *Context, roleDef *RoleDef, domains map[string][]*ManifestRecord, prune bool) (operations []*Operation, _ error) {
+	for _, domain := range slices.Sorted(maps.Keys(domains)) {
+		if _, exist := roleDef.ManagedDomains[domain]; !exist {
+			return nil, ErrPermissionDenied
+		}
+
+		type name struct {
+			subdomain string
+			current   []*Record
+			desired   []*Record
+		}
+		names := map[string]*name{}
+		get := func(canonicalName string, subdomain string) *name {
+			n, exist := names[canonicalName]
+			if !exist {
+				n = &name{subdomain: subdomain}
+				names[canonicalName] = n
+			}
+			return n
+		}
+
+		for _, record := range domains[domain] {
+			op := &Operation{
+				Record:    Record{Type: record.Type, Value: record.Value, TTL: record.TTL},
+				Op:        OP_APPEND,
+				Domain:    domain,
+				Subdomain: record.Subdomain,
+			}
+			err := ValidateOperation(roleDef, op)
+			if err != nil {
+				return nil, err
+			}
+
+			n := get(op.CanonicalName, op.Subdomain)
+			n.desired = append(n.desired, &op.Record)
+		}
+
+		current, err := ListRecords(c, roleDef, domain)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range current {
+			_, subdomain, err := SplitName(roleDef, record.CanonicalName)
+			if err != nil {
+				continue
+			}
+			n := get(record.CanonicalName, subdomain)
+			n.current = append(n.current, record)
+		}
+
+		for _, canonicalName := range slices.Sorted(maps.Keys(names)) {
+			n := names[canonicalName]
+			operations = append(operations, Diff(domain, n.subdomain, n.current, n.desired, prune)...)
+		}
+	}
+
+	return operations, nil
+}
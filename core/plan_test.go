@@ -0,0 +1,165 @@
+// Copyright 2025 Jelly Terra <jellyterra@proton.me>
+// This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0
+// that can be found in the LICENSE file and https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func formatOperations(operations []*Operation) (lines []string) {
+	for _, op := range operations {
+		lines = append(lines, fmt.Sprintf("%s %s %s %s %d", op.Op, op.CanonicalName, op.Type, op.Value, op.TTL))
+	}
+	return lines
+}
+
+func TestDiff(t *testing.T) {
+	const name = "edge-a.hosts.jellyterra.com"
+	record := func(typ string, value string, ttl int) *Record {
+		return &Record{CanonicalName: name, Type: typ, Value: value, TTL: ttl}
+	}
+
+	for _, tt := range []struct {
+		title   string
+		current []*Record
+		desired []*Record
+		prune   bool
+		want    []string
+	}{
+		{
+			title:   "no changes",
+			current: []*Record{record("A", "203.0.113.1", 300)},
+			desired: []*Record{record("A", "203.0.113.1", 300)},
+		},
+		{
+			title:   "TTL not compared if zero",
+			current: []*Record{record("A", "203.0.113.1", 300)},
+			desired: []*Record{record("A", "203.0.113.1", 0)},
+		},
+		{
+			title:   "append",
+			current: []*Record{record("A", "203.0.113.1", 300)},
+			desired: []*Record{record("A", "203.0.113.1", 300), record("AAAA", "2001:db8::1", 300)},
+			want:    []string{"append " + name + " AAAA 2001:db8::1 300"},
+		},
+		{
+			title:   "kept without prune",
+			current: []*Record{record("A", "203.0.113.1", 300), record("A", "203.0.113.2", 300)},
+			desired: []*Record{record("A", "203.0.113.1", 300)},
+		},
+		{
+			title:   "deleted with prune",
+			current: []*Record{record("A", "203.0.113.1", 300), record("A", "203.0.113.2", 300)},
+			desired: []*Record{record("A", "203.0.113.1", 300)},
+			prune:   true,
+			want:    []string{"delete " + name + " A 203.0.113.2 300"},
+		},
+		{
+			title:   "TTL change replaces the record only",
+			current: []*Record{record("A", "203.0.113.1", 300), record("MX", "10 mx.jellyterra.com", 300)},
+			desired: []*Record{record("A", "203.0.113.1", 3600), record("AAAA", "2001:db8::1", 3600)},
+			want: []string{
+				"delete " + name + " A 203.0.113.1 300",
+				"append " + name + " A 203.0.113.1 3600",
+				"append " + name + " AAAA 2001:db8::1 3600",
+			},
+		},
+		{
+			title:   "TTL change with prune",
+			current: []*Record{record("A", "203.0.113.1", 300), record("MX", "10 mx.jellyterra.com", 300)},
+			desired: []*Record{record("A", "203.0.113.1", 3600)},
+			prune:   true,
+			want: []string{
+				"delete " + name + " A 203.0.113.1 300",
+				"append " + name + " A 203.0.113.1 3600",
+				"delete " + name + " MX 10 mx.jellyterra.com 300",
+			},
+		},
+	} {
+		t.Run(tt.title, func(t *testing.T) {
+			operations := Diff("hosts.jellyterra.com", "edge-a", tt.current, tt.desired, tt.prune)
+			got := formatOperations(operations)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Diff() =\n%q\nwant\n%q", got, tt.want)
+			}
+			for _, op := range operations {
+				if op.Domain != "hosts.jellyterra.com" || op.Subdomain != "edge-a" {
+					t.Errorf("operation under %s %s", op.Domain, op.Subdomain)
+				}
+			}
+		})
+	}
+}
+
+func TestSameRecord(t *testing.T) {
+	current := &Record{CanonicalName: "jellyterra.com", Type: "A", Value: "203.0.113.1", TTL: 600}
+	for _, tt := range []struct {
+		desired Record
+		want    bool
+	}{
+		{Record{CanonicalName: "jellyterra.com", Type: "A", Value: "203.0.113.1", TTL: 600}, true},
+		{Record{CanonicalName: "jellyterra.com", Type: "A", Value: "203.0.113.1", TTL: 0}, true},
+		{Record{CanonicalName: "jellyterra.com", Type: "A", Value: "203.0.113.1", TTL: 300}, false},
+		{Record{CanonicalName: "jellyterra.com", Type: "A", Value: "203.0.113.2", TTL: 0}, false},
+	} {
+		if got := SameRecord(current, &tt.desired); got != tt.want {
+			t.Errorf("SameRecord(%+v) = %v, want %v", tt.desired, got, tt.want)
+		}
+	}
+}
+
+func TestApplySequentiallyOrder(t *testing.T) {
+	var calls []string
+	registry := &recordingRegistry{calls: &calls}
+
+	err := ApplyBatch(registry, NewBatch([]*Operation{
+		{Op: OP_APPEND, Record: Record{CanonicalName: "a.jellyterra.com", Type: "A", Value: "203.0.113.1", TTL: 3600}},
+		{Op: OP_DELETE, Record: Record{CanonicalName: "a.jellyterra.com", Type: "A", Value: "203.0.113.1", TTL: 300}},
+		{Op: OP_UPDATE, Record: Record{CanonicalName: "b.jellyterra.com", Type: "A", Value: "203.0.113.2"}},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"delete-all b.jellyterra.com",
+		"delete a.jellyterra.com 203.0.113.1",
+		"append a.jellyterra.com 203.0.113.1",
+		"append b.jellyterra.com 203.0.113.2",
+	}
+	if !slices.Equal(calls, want) {
+		t.Errorf("calls =\n%q\nwant\n%q", calls, want)
+	}
+}
+
+// recordingRegistry records the calls without any records.
+type recordingRegistry struct {
+	calls *[]string
+}
+
+func (r *recordingRegistry) ListRecords(domain string) ([]*Record, error) {
+	return nil, nil
+}
+
+func (r *recordingRegistry) AppendRecord(record *Record) error {
+	*r.calls = append(*r.calls, "append "+record.CanonicalName+" "+record.Value)
+	return nil
+}
+
+func (r *recordingRegistry) DeleteRecord(record *Record) error {
+	*r.calls = append(*r.calls, "delete "+record.CanonicalName+" "+record.Value)
+	return nil
+}
+
+func (r *recordingRegistry) DeleteAllRecordsWithDomain(domain string) error {
+	*r.calls = append(*r.calls, "delete-all "+domain)
+	return nil
+}
+
+func (r *recordingRegistry) Close() error {
+	return nil
+}
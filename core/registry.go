@@ -4,6 +4,15 @@
 
 package core
 
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
 type Record struct {
 	Type          string `json:"type"`
 	CanonicalName string `json:"name"`
@@ -12,6 +21,8 @@ type Record struct {
 }
 
 type Registry interface {
+	// ListRecords returns the records with the domain name and under it, or all records if the domain is empty.
+	ListRecords(domain string) ([]*Record, error)
 	AppendRecord(records *Record) error
 	DeleteRecord(records *Record) error
 	DeleteAllRecordsWithDomain(domain string) error
@@ -21,3 +32,123 @@ type Registry interface {
 type RegistryBuilder func(config map[string]string) (Registry, error)
 
 var RegistryBuilders = map[string]RegistryBuilder{}
+
+// ContextRegistryBuilder builds a registry composing other registries of the context.
+type ContextRegistryBuilder func(c *Context, params map[string]string) (Registry, error)
+
+var ContextRegistryBuilders = map[string]ContextRegistryBuilder{}
+
+// IsBuiltin reports whether the builder is registered in RegistryBuilders or ContextRegistryBuilders.
+func IsBuiltin(builder string) bool {
+	return RegistryBuilders[builder] != nil || ContextRegistryBuilders[builder] != nil
+}
+
+// BuildRegistry builds the registry by its definition.
+func BuildRegistry(c *Context, name string) (Registry, error) {
+	registryDef, err := Query(c, &RegistryDef{}, "registry", name)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := ContextRegistryBuilders[registryDef.Builder]
+	if b := RegistryBuilders[registryDef.Builder]; b != nil {
+		builder = func(_ *Context, params map[string]string) (Registry, error) { return b(params) }
+	}
+	if builder == nil {
+		return nil, fmt.Errorf("registry [%s] builder [%s] is not builtin", name, registryDef.Builder)
+	}
+
+	params, err := ResolveParams(registryDef.BuilderParams)
+	if err != nil {
+		return nil, fmt.Errorf("registry [%s] builder [%s] %v", name, registryDef.Builder, err)
+	}
+
+	start := time.Now()
+	registry, err := builder(c, params)
+	metricRegistryCalls.Since(start, name, registryDef.Builder, "build")
+	if err != nil {
+		metricRegistryErrors.Inc(name, registryDef.Builder, "build")
+		return nil, fmt.Errorf("registry [%s] builder [%s] failed: %s", name, registryDef.Builder, MaskSecrets(err.Error(), params))
+	}
+
+	return instrument(registry, name, registryDef.Builder), nil
+}
+
+// ListRecords returns the records under the domain that the role is allowed to see.
+func ListRecords(c *Context, roleDef *RoleDef, domain string) ([]*Record, error) {
+	var domains []string
+	if domain == "" {
+		domains = slices.Sorted(maps.Keys(roleDef.ManagedDomains))
+	} else {
+		managed, subdomain, err := SplitName(roleDef, domain)
+		if err != nil {
+			return nil, err
+		}
+		// The name itself must be visible unless it is the managed domain.
+		if subdomain != "" {
+			_, err = Validate(roleDef, managed, subdomain)
+			if err != nil {
+				return nil, err
+			}
+		}
+		domains = []string{managed}
+	}
+
+	registries := map[string]Registry{}
+	defer func() {
+		for _, registry := range registries {
+			_ = registry.Close()
+		}
+	}()
+
+	var visible []*Record
+	for _, managed := range domains {
+		registryName := roleDef.ManagedDomains[managed].Registry
+
+		registry, exist := registries[registryName]
+		if !exist {
+			var err error
+			registry, err = BuildRegistry(c, registryName)
+			if err != nil {
+				return nil, err
+			}
+			registries[registryName] = registry
+		}
+
+		name := managed
+		if domain != "" {
+			name = strings.TrimSuffix(domain, ".")
+		}
+
+		records, err := registry.ListRecords(name)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, record := range records {
+			// Records under a nested delegation are governed by it.
+			d, subdomain, err := SplitName(roleDef, record.CanonicalName)
+			if err != nil || d != managed {
+				continue
+			}
+			_, err = Validate(roleDef, managed, subdomain)
+			if err != nil {
+				continue
+			}
+			visible = append(visible, record)
+		}
+	}
+
+	slices.SortFunc(visible, CompareRecords)
+
+	return visible, nil
+}
+
+// CompareRecords orders records by name, type and value.
+func CompareRecords(a, b *Record) int {
+	return cmp.Or(
+		strings.Compare(a.CanonicalName, b.CanonicalName),
+		strings.Compare(a.Type, b.Type),
+		strings.Compare(a.Value, b.Value),
+	)
+}
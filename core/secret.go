@@ -0,0 +1,117 @@
+// Copyright 2025 Jelly Terra <jellyterra@proton.me>
+// This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0
+// that can be found in the LICENSE file and https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// SecretParamKeys are the substrings of the keys of secret builder params,
+// whose references are resolved and whose literal values are masked in output.
+var SecretParamKeys = []string{"token", "key", "secret", "password", "credential"}
+
+// IsSecretParam tells whether the builder param holds a secret by its key.
+func IsSecretParam(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range SecretParamKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSecretRef tells whether the value refers to a secret by env:NAME, file:PATH or cred:NAME.
+func IsSecretRef(value string) bool {
+	prefix, _, ok := strings.Cut(value, ":")
+	return ok && (prefix == "env" || prefix == "file" || prefix == "cred")
+}
+
+// ResolveSecret resolves the reference to the secret. Other values are returned as they are.
+//
+//	env:NAME   Environment variable.
+//	file:PATH  Content of the file.
+//	cred:NAME  systemd credential in $CREDENTIALS_DIRECTORY.
+func ResolveSecret(value string) (string, error) {
+	prefix, ref, ok := strings.Cut(value, ":")
+	if !ok {
+		return value, nil
+	}
+
+	switch prefix {
+	case "env":
+		v, exist := os.LookupEnv(ref)
+		if !exist {
+			return "", fmt.Errorf("environment variable %s is not set", ref)
+		}
+		return v, nil
+	case "file":
+		b, err := os.ReadFile(ref)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(b), "\r\n"), nil
+	case "cred":
+		dir := os.Getenv("CREDENTIALS_DIRECTORY")
+		if dir == "" {
+			return "", errors.New("CREDENTIALS_DIRECTORY is not set")
+		}
+		if ValidateName(ref) != nil {
+			return "", fmt.Errorf("invalid credential name %s", ref)
+		}
+		b, err := os.ReadFile(path.Join(dir, ref))
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(b), "\r\n"), nil
+	default:
+		return value, nil
+	}
+}
+
+// ResolveParams returns a copy of the builder params with the references of secret params resolved.
+// Other params are kept as they are, even if they look like references.
+func ResolveParams(params map[string]string) (map[string]string, error) {
+	resolved := make(map[string]string, len(params))
+	for k, v := range params {
+		if !IsSecretParam(k) {
+			resolved[k] = v
+			continue
+		}
+
+		secret, err := ResolveSecret(v)
+		if err != nil {
+			return nil, fmt.Errorf("param [%s]: %v", k, err)
+		}
+		resolved[k] = secret
+	}
+	return resolved, nil
+}
+
+// MaskSecrets masks the values of the secret params in the text, e.g. errors echoing a token.
+func MaskSecrets(text string, params map[string]string) string {
+	for k, v := range params {
+		if v != "" && IsSecretParam(k) {
+			text = strings.ReplaceAll(text, v, "********")
+		}
+	}
+	return text
+}
+
+// MaskParam returns the value of the builder param for output, with literal secrets masked.
+func MaskParam(key string, value string) string {
+	if IsSecretRef(value) {
+		return value
+	}
+
+	if IsSecretParam(key) {
+		return "********"
+	}
+	return value
+}
@@ -0,0 +1,310 @@
+// Copyright 2025 Jelly Terra <jellyterra@proton.me>
+// This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0
+// that can be found in the LICENSE file and https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var (
+	ErrInvalidName      = errors.New("invalid name")
+	ErrWatchUnsupported = errors.New("watch unsupported")
+)
+
+// ValidateName rejects names that are empty, absolute, contain separators or control characters,
+// or resolve to traversal once percent-decoded, so that every document stays under the config dir.
+func ValidateName(name string) error {
+	decoded, err := url.PathUnescape(name)
+	if err != nil {
+		return fmt.Errorf("%w [%s]: %v", ErrInvalidName, name, err)
+	}
+
+	for _, s := range []string{name, decoded} {
+		switch {
+		case s == "", s == ".", s == "..":
+		case strings.ContainsAny(s, "/\\:"):
+		case strings.ContainsFunc(s, unicode.IsControl):
+		default:
+			continue
+		}
+		return fmt.Errorf("%w [%s]", ErrInvalidName, name)
+	}
+	return nil
+}
+
+func validateNames(kind string, name string) error {
+	err := ValidateName(kind)
+	if err != nil {
+		return err
+	}
+	return ValidateName(name)
+}
+
+// Kinds of config documents.
+var Kinds = []string{"role", "registry", "webhook"}
+
+// StorePollInterval is the interval of polling stores for changes.
+var StorePollInterval = 2 * time.Second
+
+// Store keeps config documents in JSON by kind and name, e.g. role and registry.
+// Lookups of absent documents fail with fs.ErrNotExist.
+type Store interface {
+	// Revision returns a number that changes whenever the document changes.
+	Revision(kind string, name string) (int64, error)
+
+	Get(kind string, name string) ([]byte, error)
+	Put(kind string, name string, data []byte) error
+	Delete(kind string, name string) error
+
+	// List returns the names of documents of the kind.
+	List(kind string) ([]string, error)
+
+	// Watch starts calling the function with each document that has been changed, created or deleted, until the context is done.
+	// Stores unable to notify changes return ErrWatchUnsupported, and are polled instead.
+	Watch(ctx context.Context, fn func(kind string, name string)) error
+
+	// Lock takes an advisory lock of the store across processes for read-modify-write cycles.
+	Lock() (unlock func() error, _ error)
+
+	Close() error
+}
+
+// OpenStore opens the store of the type under the config dir.
+func OpenStore(typ string, dir string) (Store, error) {
+	switch typ {
+	case "fs":
+		return &FSStore{Dir: dir}, nil
+	case "bolt":
+		return OpenBoltStore(path.Join(dir, "autodns.db"))
+	default:
+		return nil, fmt.Errorf("unknown store %s", typ)
+	}
+}
+
+// Load reads the document bypassing the cache.
+func Load[T any](s Store, v *T, kind string, name string) (*T, error) {
+	b, err := s.Get(kind, name)
+	if err != nil {
+		return nil, err
+	}
+	return v, json.Unmarshal(b, v)
+}
+
+func Save(s Store, kind string, name string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.Put(kind, name, b)
+}
+
+// CopyStore copies all documents from one store to another.
+func CopyStore(from Store, to Store) (n int, _ error) {
+	for _, kind := range Kinds {
+		names, err := from.List(kind)
+		if err != nil {
+			return n, err
+		}
+		for _, name := range names {
+			b, err := from.Get(kind, name)
+			if err != nil {
+				return n, err
+			}
+			err = to.Put(kind, name, b)
+			if err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+	return n, nil
+}
+
+// pollStore watches the store by comparing revisions periodically.
+func pollStore(ctx context.Context, s Store, fn func(kind string, name string)) error {
+	scan := func() map[[2]string]int64 {
+		revisions := map[[2]string]int64{}
+		for _, kind := range Kinds {
+			names, _ := s.List(kind)
+			for _, name := range names {
+				revision, err := s.Revision(kind, name)
+				if err == nil {
+					revisions[[2]string{kind, name}] = revision
+				}
+			}
+		}
+		return revisions
+	}
+
+	last := scan()
+
+	ticker := time.NewTicker(StorePollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		current := scan()
+		for k, revision := range current {
+			if last[k] != revision {
+				fn(k[0], k[1])
+			}
+		}
+		for k := range last {
+			if _, exist := current[k]; !exist {
+				fn(k[0], k[1])
+			}
+		}
+		last = current
+	}
+}
+
+// FSStore keeps each document in <Dir>/<kind>/<name>.json, and its previous version in <name>.json.bak.
+// Documents can also be in YAML or TOML by extension, and are written back in the same format.
+type FSStore struct {
+	Dir string
+}
+
+// path returns the existing file of the document, or the JSON file if there is none.
+func (s *FSStore) path(kind string, name string) (string, error) {
+	err := validateNames(kind, name)
+	if err != nil {
+		return "", err
+	}
+
+	for _, ext := range ConfigExts {
+		p := path.Join(s.Dir, kind, name+ext)
+		_, err = os.Stat(p)
+		if err == nil {
+			return p, nil
+		}
+	}
+	return path.Join(s.Dir, kind, name+".json"), nil
+}
+
+func (s *FSStore) Revision(kind string, name string) (int64, error) {
+	p, err := s.path(kind, name)
+	if err != nil {
+		return 0, err
+	}
+	stat, err := os.Stat(p)
+	if err != nil {
+		return 0, err
+	}
+	return stat.ModTime().UnixNano(), nil
+}
+
+func (s *FSStore) Get(kind string, name string) ([]byte, error) {
+	p, err := s.path(kind, name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(p)
+	if err != nil || IsEncrypted(data) {
+		// Encrypted documents are kept as they are in any format.
+		return data, err
+	}
+	return ToJSON(p, data)
+}
+
+func (s *FSStore) Put(kind string, name string, data []byte) error {
+	p, err := s.path(kind, name)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(path.Dir(p), 0700)
+	if err != nil {
+		return err
+	}
+	if !IsEncrypted(data) {
+		data, err = FromJSON(p, data)
+		if err != nil {
+			return err
+		}
+	}
+	return WriteFileAtomic(p, data, 0600, true)
+}
+
+func (s *FSStore) Delete(kind string, name string) error {
+	p, err := s.path(kind, name)
+	if err != nil {
+		return err
+	}
+	return os.Rename(p, p+".bak")
+}
+
+func (s *FSStore) List(kind string) (names []string, _ error) {
+	err := ValidateName(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(path.Join(s.Dir, kind))
+	if errors.Is(err, fs.ErrNotExist) {
+		// Nothing of the kind has been created yet, as long as the config dir exists.
+		_, err = os.ReadDir(s.Dir)
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		name, ok := cutConfigExt(entry.Name())
+		if ok && !entry.IsDir() && ValidateName(name) == nil && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// orphanBackups returns the paths of the backups of the kind whose documents have been deleted.
+func (s *FSStore) orphanBackups(kind string) (paths []string, _ error) {
+	entries, err := os.ReadDir(path.Join(s.Dir, kind))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		fName, ok := strings.CutSuffix(entry.Name(), ".bak")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		if _, ok = cutConfigExt(fName); !ok {
+			continue
+		}
+		_, err = os.Stat(path.Join(s.Dir, kind, fName))
+		if errors.Is(err, fs.ErrNotExist) {
+			paths = append(paths, path.Join(s.Dir, kind, entry.Name()))
+		}
+	}
+	return paths, nil
+}
+
+func (s *FSStore) Close() error {
+	return nil
+}
+
+func (s *FSStore) Lock() (func() error, error) {
+	return LockFile(path.Join(s.Dir, ".lock"))
+}
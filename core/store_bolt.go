@@ -0,0 +1,259 @@
+// Copyright 2025 Jelly Terra <jellyterra@proton.me>
+// This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0
+// that can be found in the LICENSE file and https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"strings"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+var (
+	boltRevisionBucket = []byte("_revision")
+	// boltStoreRevision is bumped with every change, so watching reads one key rather than every document.
+	boltStoreRevision = []byte("_store")
+)
+
+// BoltStore keeps all documents in one bbolt database, a bucket per kind.
+// The database is opened per transaction, read-only for reads, so the server and server-config can use it at once.
+type BoltStore struct {
+	Path string
+}
+
+// OpenBoltStore creates the database if it does not exist.
+func OpenBoltStore(path string) (*BoltStore, error) {
+	s := &BoltStore{Path: path}
+	return s, s.update(func(tx *bbolt.Tx) error { return nil })
+}
+
+func (s *BoltStore) Close() error {
+	return nil
+}
+
+// open opens the database, waiting for the writer holding it if any.
+func (s *BoltStore) open(readOnly bool) (*bbolt.DB, error) {
+	db, err := bbolt.Open(s.Path, 0600, &bbolt.Options{Timeout: 5 * time.Second, ReadOnly: readOnly})
+	if errors.Is(err, bbolt.ErrTimeout) {
+		return nil, fmt.Errorf("%s: %v, held by another process", s.Path, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", s.Path, err)
+	}
+	return db, nil
+}
+
+func (s *BoltStore) view(fn func(tx *bbolt.Tx) error) error {
+	db, err := s.open(true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.View(fn)
+}
+
+func (s *BoltStore) update(fn func(tx *bbolt.Tx) error) error {
+	db, err := s.open(false)
+	if err != nil {
+		return err
+	}
+	err = db.Update(fn)
+	if err != nil {
+		_ = db.Close()
+		return err
+	}
+	return db.Close()
+}
+
+// bump puts the next revision to the document and the store.
+func bump(tx *bbolt.Tx, kind string, name string, deleted bool) error {
+	rb, err := tx.CreateBucketIfNotExists(boltRevisionBucket)
+	if err != nil {
+		return err
+	}
+	seq, err := rb.NextSequence()
+	if err != nil {
+		return err
+	}
+	revision := binary.BigEndian.AppendUint64(nil, seq)
+
+	if deleted {
+		err = rb.Delete([]byte(kind + "/" + name))
+	} else {
+		err = rb.Put([]byte(kind+"/"+name), revision)
+	}
+	if err != nil {
+		return err
+	}
+	return rb.Put(boltStoreRevision, revision)
+}
+
+func notExist(kind string, name string) error {
+	return fmt.Errorf("%s/%s: %w", kind, name, fs.ErrNotExist)
+}
+
+func (s *BoltStore) Revision(kind string, name string) (revision int64, _ error) {
+	err := validateNames(kind, name)
+	if err != nil {
+		return 0, err
+	}
+	return revision, s.view(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(boltRevisionBucket)
+		if b == nil {
+			return notExist(kind, name)
+		}
+		v := b.Get([]byte(kind + "/" + name))
+		if v == nil {
+			return notExist(kind, name)
+		}
+		revision = int64(binary.BigEndian.Uint64(v))
+		return nil
+	})
+}
+
+func (s *BoltStore) Get(kind string, name string) (data []byte, _ error) {
+	err := validateNames(kind, name)
+	if err != nil {
+		return nil, err
+	}
+	return data, s.view(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(kind))
+		if b == nil {
+			return notExist(kind, name)
+		}
+		v := b.Get([]byte(name))
+		if v == nil {
+			return notExist(kind, name)
+		}
+		// The value is only valid during the transaction.
+		data = append([]byte{}, v...)
+		return nil
+	})
+}
+
+func (s *BoltStore) Put(kind string, name string, data []byte) error {
+	err := validateNames(kind, name)
+	if err != nil {
+		return err
+	}
+	return s.update(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(kind))
+		if err != nil {
+			return err
+		}
+		err = b.Put([]byte(name), data)
+		if err != nil {
+			return err
+		}
+		return bump(tx, kind, name, false)
+	})
+}
+
+func (s *BoltStore) Delete(kind string, name string) error {
+	err := validateNames(kind, name)
+	if err != nil {
+		return err
+	}
+	return s.update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(kind))
+		if b == nil || b.Get([]byte(name)) == nil {
+			return notExist(kind, name)
+		}
+		err := b.Delete([]byte(name))
+		if err != nil {
+			return err
+		}
+		return bump(tx, kind, name, true)
+	})
+}
+
+func (s *BoltStore) List(kind string) (names []string, _ error) {
+	return names, s.view(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(kind))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, _ []byte) error {
+			names = append(names, string(k))
+			return nil
+		})
+	})
+}
+
+// revisions returns the revision of the store, and of each document if the store revision differs from the last.
+func (s *BoltStore) revisions(last uint64) (store uint64, documents map[string]uint64, _ error) {
+	return store, documents, s.view(func(tx *bbolt.Tx) error {
+		rb := tx.Bucket(boltRevisionBucket)
+		if rb == nil {
+			return nil
+		}
+		if v := rb.Get(boltStoreRevision); v != nil {
+			store = binary.BigEndian.Uint64(v)
+		}
+		if store == last {
+			return nil
+		}
+
+		documents = map[string]uint64{}
+		return rb.ForEach(func(k, v []byte) error {
+			if !bytes.Equal(k, boltStoreRevision) {
+				documents[string(k)] = binary.BigEndian.Uint64(v)
+			}
+			return nil
+		})
+	})
+}
+
+// Watch polls the store revision, and compares the document revisions once it changes.
+func (s *BoltStore) Watch(ctx context.Context, fn func(kind string, name string)) error {
+	store, documents, err := s.revisions(math.MaxUint64)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(StorePollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			current, currentDocuments, err := s.revisions(store)
+			if err != nil || current == store {
+				continue
+			}
+			for k, revision := range currentDocuments {
+				if documents[k] != revision {
+					kind, name, _ := strings.Cut(k, "/")
+					fn(kind, name)
+				}
+			}
+			for k := range documents {
+				if _, exist := currentDocuments[k]; !exist {
+					kind, name, _ := strings.Cut(k, "/")
+					fn(kind, name)
+				}
+			}
+			store, documents = current, currentDocuments
+		}
+	}()
+
+	return nil
+}
+
+func (s *BoltStore) Lock() (func() error, error) {
+	return LockFile(s.Path + ".lock")
+}
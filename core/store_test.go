@@ -0,0 +1,152 @@
+// Copyright 2025 Jelly Terra <jellyterra@proton.me>
+// This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0
+// that can be found in the LICENSE file and https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestValidateName(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		valid bool
+	}{
+		{"jellyterra", true},
+		{"jellyterra.com", true},
+		{"edge-a_1", true},
+		{"..jellyterra", true},
+
+		{"", false},
+		{".", false},
+		{"..", false},
+		{"%2e%2e", false},
+		{"%2E.", false},
+
+		{"/etc/passwd", false},
+		{"/", false},
+		{"C:", false},
+		{`\\server\share`, false},
+
+		{"a/b", false},
+		{`a\b`, false},
+		{"a:b", false},
+		{"a%2fb", false},
+		{"a%5Cb", false},
+
+		{"a\x00b", false},
+		{"a%00b", false},
+		{"a\nb", false},
+		{"a\x7fb", false},
+
+		{"a%zzb", false},
+	} {
+		err := ValidateName(tt.name)
+		if tt.valid && err != nil {
+			t.Errorf("ValidateName(%q) = %v, want nil", tt.name, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidName) {
+			t.Errorf("ValidateName(%q) = %v, want ErrInvalidName", tt.name, err)
+		}
+	}
+}
+
+func TestBoltStoreWatch(t *testing.T) {
+	interval := StorePollInterval
+	StorePollInterval = 10 * time.Millisecond
+	defer func() { StorePollInterval = interval }()
+
+	p := path.Join(t.TempDir(), "autodns.db")
+	server, err := OpenBoltStore(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changed := make(chan string, 1)
+	err = server.Watch(ctx, func(kind string, name string) {
+		changed <- kind + "/" + name
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another store on the same database, as server-config while the server runs.
+	editor, err := OpenBoltStore(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer editor.Close()
+	err = editor.Put("role", "jellyterra", []byte("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case k := <-changed:
+		if k != "role/jellyterra" {
+			t.Errorf("changed %s", k)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change watched")
+	}
+
+	data, err := server.Get("role", "jellyterra")
+	if err != nil || string(data) != "{}" {
+		t.Errorf("Get() = %q, %v", data, err)
+	}
+}
+
+func TestWatchReloadsNewDocuments(t *testing.T) {
+	interval := StorePollInterval
+	StorePollInterval = 10 * time.Millisecond
+	defer func() { StorePollInterval = interval }()
+
+	dir := t.TempDir()
+	err := os.Mkdir(path.Join(dir, "role"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := make(chan string, 1)
+	c := &Context{
+		Store: &FSStore{Dir: dir},
+		Cache: map[string]*ContextCache{},
+		OnReload: func(kind string, name string) {
+			select {
+			case reloaded <- kind + "/" + name:
+			default:
+			}
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, err = c.Watch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Never looked up, so never cached.
+	err = c.Store.Put("role", "jellyterra", []byte("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case k := <-reloaded:
+		if k != "role/jellyterra" {
+			t.Errorf("reloaded %s", k)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reload of the new document")
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright 2025 Jelly Terra <jellyterra@proton.me>
+// This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0
+// that can be found in the LICENSE file and https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"regexp"
+	"slices"
+	"time"
+)
+
+// CONFIG_VERSION is the version of config written by this build. Config without version is of version 1.
+const CONFIG_VERSION = 1
+
+const (
+	ISSUE_ERROR   = "error"
+	ISSUE_WARNING = "warning"
+)
+
+// Issue is a problem found in config.
+type Issue struct {
+	Config   string `json:"config"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+func (i *Issue) String() string {
+	return fmt.Sprintf("%s: %s: %s", i.Config, i.Severity, i.Message)
+}
+
+// DecodeStrict decodes the JSON, rejecting unknown fields.
+func DecodeStrict[T any](data []byte, v *T) (*T, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return v, decoder.Decode(v)
+}
+
+// CheckVersion rejects config written by a newer build.
+func CheckVersion(version int) error {
+	if version > CONFIG_VERSION {
+		return fmt.Errorf("version %d is newer than supported version %d", version, CONFIG_VERSION)
+	}
+	return nil
+}
+
+// ValidateConfig checks every config in the store.
+func ValidateConfig(s Store) (issues []*Issue, _ error) {
+	// Builder params of each registry, whose secrets are masked in the issues.
+	params := map[string]map[string]string{}
+
+	report := func(config string, severity string, format string, a ...any) {
+		message := MaskSecrets(fmt.Sprintf(format, a...), params[config])
+		issues = append(issues, &Issue{Config: config, Severity: severity, Message: message})
+	}
+	get := func(kind string, name string) []byte {
+		data, err := s.Get(kind, name)
+		switch {
+		case err != nil:
+			report(kind+"/"+name, ISSUE_ERROR, "%v", err)
+			return nil
+		case IsEncrypted(data):
+			report(kind+"/"+name, ISSUE_ERROR, "encrypted, requires the master key")
+			return nil
+		}
+		return data
+	}
+
+	registries, err := s.List("registry")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range registries {
+		config := "registry/" + name
+		data := get("registry", name)
+		if data == nil {
+			continue
+		}
+
+		def, err := DecodeStrict(data, &RegistryDef{})
+		if err != nil {
+			report(config, ISSUE_ERROR, "%v", err)
+			continue
+		}
+		params[config] = def.BuilderParams
+
+		if err = CheckVersion(def.Version); err != nil {
+			report(config, ISSUE_ERROR, "%v", err)
+		}
+		if !IsBuiltin(def.Builder) {
+			report(config, ISSUE_ERROR, "builder [%s] is not builtin", def.Builder)
+		}
+		for _, key := range slices.Sorted(maps.Keys(def.BuilderParams)) {
+			value := def.BuilderParams[key]
+			switch {
+			case !IsSecretParam(key) || value == "":
+			case !IsSecretRef(value):
+				report(config, ISSUE_WARNING, "param [%s] holds a literal secret, prefer a reference", key)
+			default:
+				// The server might see other environment and credentials.
+				_, err := ResolveSecret(value)
+				if err != nil {
+					report(config, ISSUE_WARNING, "param [%s] is unresolvable here: %v", key, err)
+				}
+			}
+		}
+	}
+
+	roles, err := s.List("role")
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	for _, name := range roles {
+		config := "role/" + name
+		data := get("role", name)
+		if data == nil {
+			continue
+		}
+
+		def, err := DecodeStrict(data, &RoleDef{})
+		if err != nil {
+			report(config, ISSUE_ERROR, "%v", err)
+			continue
+		}
+		if err = CheckVersion(def.Version); err != nil {
+			report(config, ISSUE_ERROR, "%v", err)
+		}
+
+		for domain, d := range def.ManagedDomains {
+			if !slices.Contains(registries, d.Registry) {
+				report(config, ISSUE_ERROR, "domain [%s] is delegated under registry [%s] which does not exist", domain, d.Registry)
+			}
+			switch d.Glob {
+			case "", "*":
+			default:
+				_, err := regexp.Compile(d.Glob)
+				if err != nil {
+					report(config, ISSUE_ERROR, "domain [%s] glob pattern: %v", domain, err)
+				}
+			}
+		}
+
+		for key, k := range def.Keys {
+			if k.Expire != 0 && k.Expire < now {
+				report(config, ISSUE_WARNING, "key of ID [%s] expired at %s", KeyID(key), time.Unix(k.Expire, 0).UTC().Format(time.RFC3339))
+			}
+		}
+	}
+
+	webhooks, err := s.List("webhook")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range webhooks {
+		config := "webhook/" + name
+		data := get("webhook", name)
+		if data == nil {
+			continue
+		}
+
+		def, err := DecodeStrict(data, &WebhookDef{})
+		if err != nil {
+			report(config, ISSUE_ERROR, "%v", err)
+			continue
+		}
+		if err = CheckVersion(def.Version); err != nil {
+			report(config, ISSUE_ERROR, "%v", err)
+		}
+		u, err := url.Parse(def.URL)
+		if err != nil || u.Scheme != "http" && u.Scheme != "https" {
+			report(config, ISSUE_ERROR, "url [%s] is not an HTTP URL", def.URL)
+		}
+		for _, registry := range def.Registries {
+			if !slices.Contains(registries, registry) {
+				report(config, ISSUE_WARNING, "registry [%s] does not exist", registry)
+			}
+		}
+	}
+
+	return issues, nil
+}
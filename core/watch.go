@@ -0,0 +1,13 @@
+// Copyright 2025 Jelly Terra <jellyterra@proton.me>
+// This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0
+// that can be found in the LICENSE file and https://mozilla.org/MPL/2.0/.
+
+//go:build !linux
+
+package core
+
+import "context"
+
+func (s *FSStore) Watch(ctx context.Context, fn func(kind string, name string)) error {
+	return ErrWatchUnsupported
+}
@@ -0,0 +1,110 @@
+// Copyright 2025 Jelly Terra <jellyterra@proton.me>
+// This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0
+// that can be found in the LICENSE file and https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path"
+	"slices"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	inotifyDirMask  = unix.IN_CREATE | unix.IN_MOVED_TO | unix.IN_ONLYDIR
+	inotifyKindMask = unix.IN_CLOSE_WRITE | unix.IN_MOVED_TO | unix.IN_MOVED_FROM | unix.IN_DELETE | unix.IN_ONLYDIR
+)
+
+// Watch watches the config dir and the dir of each kind by inotify.
+func (s *FSStore) Watch(ctx context.Context, fn func(kind string, name string)) error {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return ErrWatchUnsupported
+	}
+	// Non-blocking for the runtime poller, so that closing it stops reading.
+	f := os.NewFile(uintptr(fd), "inotify")
+
+	_, err = unix.InotifyAddWatch(fd, s.Dir, inotifyDirMask)
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	kinds := map[int]string{}
+	watchKind := func(kind string) error {
+		wd, err := unix.InotifyAddWatch(fd, path.Join(s.Dir, kind), inotifyKindMask)
+		if err != nil {
+			return err
+		}
+		kinds[wd] = kind
+		return nil
+	}
+
+	for _, kind := range Kinds {
+		err = watchKind(kind)
+		if err != nil && !os.IsNotExist(err) {
+			_ = f.Close()
+			return err
+		}
+	}
+
+	// Notifies every document of the kind, of which changes might have been missed.
+	notifyAll := func(kind string) {
+		names, _ := s.List(kind)
+		for _, name := range names {
+			fn(kind, name)
+		}
+	}
+
+	go func() {
+		<-ctx.Done()
+		_ = f.Close()
+	}()
+
+	go func() {
+		buf := make([]byte, 64<<10)
+		for {
+			n, err := f.Read(buf)
+			if err != nil {
+				return
+			}
+
+			for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+				event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+				nameBytes := buf[offset+unix.SizeofInotifyEvent : offset+unix.SizeofInotifyEvent+int(event.Len)]
+				name := string(bytes.TrimRight(nameBytes, "\x00"))
+				offset += unix.SizeofInotifyEvent + int(event.Len)
+
+				switch {
+				case event.Mask&unix.IN_Q_OVERFLOW != 0:
+					for _, kind := range Kinds {
+						notifyAll(kind)
+					}
+				case event.Mask&unix.IN_IGNORED != 0:
+					delete(kinds, int(event.Wd))
+				case event.Mask&unix.IN_ISDIR != 0:
+					// Dir of a kind has been created.
+					if slices.Contains(Kinds, name) && watchKind(name) == nil {
+						notifyAll(name)
+					}
+				default:
+					kind, exist := kinds[int(event.Wd)]
+					if !exist {
+						continue
+					}
+					name, ok := cutConfigExt(name)
+					if ok && ValidateName(name) == nil {
+						fn(kind, name)
+					}
+				}
+			}
+		}
+	}()
+
+	return nil
+}
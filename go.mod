@@ -3,14 +3,27 @@ module github.com/autodns/autodns.go
 go 1.24
 
 require (
+	github.com/BurntSushi/toml v1.5.0
 	github.com/cloudflare/cloudflare-go v0.115.0
+	github.com/prometheus/client_golang v1.22.0
+	go.etcd.io/bbolt v1.4.0
+	golang.org/x/crypto v0.39.0
 	golang.org/x/net v0.41.0
+	golang.org/x/sys v0.33.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
 	github.com/goccy/go-json v0.10.5 // indirect
-	github.com/google/go-cmp v0.7.0 // indirect
 	github.com/google/go-querystring v1.1.0 // indirect
+	github.com/kr/text v0.2.0 // indirect
+	github.com/munnerz/goautoneg v0.0.0-20191010083416-a7dc8b61c822 // indirect
+	github.com/prometheus/client_model v0.6.1 // indirect
+	github.com/prometheus/common v0.62.0 // indirect
+	github.com/prometheus/procfs v0.15.1 // indirect
 	golang.org/x/text v0.26.0 // indirect
 	golang.org/x/time v0.12.0 // indirect
+	google.golang.org/protobuf v1.36.5 // indirect
 )
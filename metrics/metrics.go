@@ -0,0 +1,59 @@
+// Copyright 2025 Jelly Terra <jellyterra@proton.me>
+// This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0
+// that can be found in the LICENSE file and https://mozilla.org/MPL/2.0/.
+
+// Package metrics registers counters and histograms in the default Prometheus registry.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var DefaultBuckets = prometheus.DefBuckets
+
+type CounterVec struct {
+	*prometheus.CounterVec
+}
+
+// NewCounterVec creates and registers the counter.
+func NewCounterVec(name string, help string, labels ...string) *CounterVec {
+	return &CounterVec{promauto.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help}, labels)}
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.WithLabelValues(labelValues...).Inc()
+}
+
+func (c *CounterVec) Add(delta uint64, labelValues ...string) {
+	c.WithLabelValues(labelValues...).Add(float64(delta))
+}
+
+type HistogramVec struct {
+	*prometheus.HistogramVec
+}
+
+// NewHistogramVec creates and registers the histogram. Nil buckets to be DefaultBuckets.
+func NewHistogramVec(name string, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	return &HistogramVec{promauto.NewHistogramVec(prometheus.HistogramOpts{Name: name, Help: help, Buckets: buckets}, labels)}
+}
+
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	h.WithLabelValues(labelValues...).Observe(value)
+}
+
+// Since observes the seconds elapsed from the start.
+func (h *HistogramVec) Since(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
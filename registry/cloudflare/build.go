@@ -6,7 +6,12 @@ package cloudflare
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
 	"github.com/autodns/autodns.go/core"
 	"github.com/cloudflare/cloudflare-go"
 )
@@ -16,26 +21,64 @@ type Registry struct {
 	API *cloudflare.API
 	RC  *cloudflare.ResourceContainer
 
+	// RecordMap is listed once in Build, and kept current by the writes.
 	RecordMap map[string][]cloudflare.DNSRecord
 }
 
+// forget removes the record of the ID from RecordMap.
+func (r *Registry) forget(name string, id string) {
+	r.RecordMap[name] = slices.DeleteFunc(r.RecordMap[name], func(rec cloudflare.DNSRecord) bool {
+		return rec.ID == id
+	})
+	if len(r.RecordMap[name]) == 0 {
+		delete(r.RecordMap, name)
+	}
+}
+
+func (r *Registry) ListRecords(domain string) (records []*core.Record, _ error) {
+	for name, recs := range r.RecordMap {
+		if domain != "" && name != domain && !strings.HasSuffix(name, "."+domain) {
+			continue
+		}
+		for _, rec := range recs {
+			records = append(records, &core.Record{
+				Type:          rec.Type,
+				CanonicalName: rec.Name,
+				Value:         strings.Trim(rec.Content, "\""),
+				TTL:           rec.TTL,
+			})
+		}
+	}
+	return records, nil
+}
+
 func (r *Registry) AppendRecord(record *core.Record) error {
-	_, err := r.API.CreateDNSRecord(r.Ctx, r.RC, cloudflare.CreateDNSRecordParams{
+	rec, err := r.API.CreateDNSRecord(r.Ctx, r.RC, cloudflare.CreateDNSRecordParams{
 		Type:    record.Type,
 		Name:    record.CanonicalName,
 		Content: record.Value,
 		TTL:     record.TTL,
 	})
-	return err
+	if err != nil {
+		return err
+	}
+	r.RecordMap[rec.Name] = append(r.RecordMap[rec.Name], rec)
+	return nil
+}
+
+// matches reports whether the record of Cloudflare is the record, compared by type only if given.
+func matches(rec cloudflare.DNSRecord, record *core.Record) bool {
+	return (record.Type == "" || rec.Type == record.Type) && strings.Trim(rec.Content, "\"") == record.Value
 }
 
 func (r *Registry) DeleteRecord(record *core.Record) error {
 	for _, rec := range r.RecordMap[record.CanonicalName] {
-		if rec.Content == record.Value {
+		if matches(rec, record) {
 			err := r.API.DeleteDNSRecord(r.Ctx, r.RC, rec.ID)
 			if err != nil {
 				return err
 			}
+			r.forget(rec.Name, rec.ID)
 			break
 		}
 	}
@@ -43,11 +86,80 @@ func (r *Registry) DeleteRecord(record *core.Record) error {
 }
 
 func (r *Registry) DeleteAllRecordsWithDomain(domain string) error {
-	for _, record := range r.RecordMap[domain] {
+	for _, record := range slices.Clone(r.RecordMap[domain]) {
 		err := r.API.DeleteDNSRecord(r.Ctx, r.RC, record.ID)
 		if err != nil {
 			return err
 		}
+		r.forget(record.Name, record.ID)
+	}
+	return nil
+}
+
+type batchRecord struct {
+	ID      string `json:"id,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Content string `json:"content,omitempty"`
+	TTL     int    `json:"ttl,omitempty"`
+}
+
+// ApplyBatch applies the changes in one request to the batch endpoint, which Cloudflare commits or rejects as a whole.
+func (r *Registry) ApplyBatch(batch *core.Batch) error {
+	var (
+		deletes []batchRecord
+		posts   []batchRecord
+		deleted []cloudflare.DNSRecord
+	)
+	del := func(rec cloudflare.DNSRecord) {
+		if !slices.ContainsFunc(deleted, func(d cloudflare.DNSRecord) bool { return d.ID == rec.ID }) {
+			deleted = append(deleted, rec)
+			deletes = append(deletes, batchRecord{ID: rec.ID})
+		}
+	}
+
+	for _, domain := range batch.DeleteAll {
+		for _, rec := range r.RecordMap[domain] {
+			del(rec)
+		}
+	}
+	for _, record := range batch.Delete {
+		for _, rec := range r.RecordMap[record.CanonicalName] {
+			if matches(rec, record) {
+				del(rec)
+				break
+			}
+		}
+	}
+	for _, record := range batch.Append {
+		posts = append(posts, batchRecord{
+			Type:    record.Type,
+			Name:    record.CanonicalName,
+			Content: record.Value,
+			TTL:     record.TTL,
+		})
+	}
+
+	resp, err := r.API.Raw(r.Ctx, http.MethodPost, "/zones/"+r.RC.Identifier+"/dns_records/batch", map[string][]batchRecord{
+		"deletes": deletes,
+		"posts":   posts,
+	}, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, rec := range deleted {
+		r.forget(rec.Name, rec.ID)
+	}
+	var result struct {
+		Posts []cloudflare.DNSRecord `json:"posts"`
+	}
+	err = json.Unmarshal(resp.Result, &result)
+	if err != nil {
+		return fmt.Errorf("cloudflare: batch applied but its result is unreadable: %v", err)
+	}
+	for _, rec := range result.Posts {
+		r.RecordMap[rec.Name] = append(r.RecordMap[rec.Name], rec)
 	}
 	return nil
 }
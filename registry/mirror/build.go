@@ -0,0 +1,289 @@
+// Copyright 2025 Jelly Terra <jellyterra@proton.me>
+// This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0
+// that can be found in the LICENSE file and https://mozilla.org/MPL/2.0/.
+
+// Package mirror provides a registry writing to several registries holding the same zone.
+package mirror
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/autodns/autodns.go/core"
+	"github.com/autodns/autodns.go/metrics"
+)
+
+var metricSecondaryFailures = metrics.NewCounterVec("autodns_mirror_secondary_failures_total",
+	"Writes failed in secondaries of mirrors in primary mode.", "registry")
+
+const (
+	// MODE_ALL requires writes to succeed in all registries.
+	MODE_ALL = "all"
+	// MODE_PRIMARY requires writes to succeed in the primary, and tries the secondaries at best effort.
+	MODE_PRIMARY = "primary"
+)
+
+type Registry struct {
+	// Names are the names of the registries, the first is the primary.
+	Names      []string
+	Registries []core.Registry
+	Mode       string
+
+	views map[core.Registry]*view
+}
+
+// ListRecords reads from the primary.
+func (r *Registry) ListRecords(domain string) ([]*core.Record, error) {
+	return r.Registries[0].ListRecords(domain)
+}
+
+// write calls the registries in order. A failure in the primary stops the secondaries from diverging.
+func (r *Registry) write(call func(registry core.Registry) error) error {
+	err := call(r.Registries[0])
+	if err != nil {
+		return fmt.Errorf("mirror [%s]: %v", r.Names[0], err)
+	}
+
+	var errs []error
+	for i, registry := range r.Registries[1:] {
+		name := r.Names[i+1]
+
+		err := call(registry)
+		if err == nil {
+			continue
+		}
+		if r.Mode == MODE_PRIMARY {
+			metricSecondaryFailures.Inc(name)
+			slog.Warn("writing to mirror secondary failed", "registry", name, "error", err)
+			continue
+		}
+		errs = append(errs, fmt.Errorf("mirror [%s]: %v", name, err))
+	}
+	return errors.Join(errs...)
+}
+
+// view is what the mirror knows a registry holds, listing each name once and following the writes through the mirror.
+// The registry might list from a cache of when it was built, so its listing is not asked again after the writes.
+type view struct {
+	registry core.Registry
+	listed   map[string][]*core.Record
+}
+
+// view returns the view of the registry, which is one of the mirror.
+func (r *Registry) view(registry core.Registry) *view {
+	if r.views == nil {
+		r.views = map[core.Registry]*view{}
+	}
+	v := r.views[registry]
+	if v == nil {
+		v = &view{registry: registry, listed: map[string][]*core.Record{}}
+		r.views[registry] = v
+	}
+	return v
+}
+
+// holds reports whether the registry holds the record. TTL is compared only if exact.
+func (v *view) holds(record *core.Record, exact bool) (bool, error) {
+	name := record.CanonicalName
+	records, exist := v.listed[name]
+	if !exist {
+		listed, err := v.registry.ListRecords(name)
+		if err != nil {
+			return false, fmt.Errorf("listing records with domain [%s] failed: %v", name, err)
+		}
+		for _, r := range listed {
+			if r.CanonicalName == name {
+				records = append(records, r)
+			}
+		}
+		v.listed[name] = records
+	}
+
+	want := *record
+	if !exact {
+		want.TTL = 0
+	}
+	return slices.ContainsFunc(records, func(r *core.Record) bool { return core.SameRecord(r, &want) }), nil
+}
+
+// apply follows the batch applied to the registry, or forgets the names if it failed as they might have changed in part.
+func (v *view) apply(batch *core.Batch, err error) {
+	if err != nil {
+		for _, name := range batch.DeleteAll {
+			delete(v.listed, name)
+		}
+		for _, record := range slices.Concat(batch.Delete, batch.Append) {
+			delete(v.listed, record.CanonicalName)
+		}
+		return
+	}
+
+	for _, name := range batch.DeleteAll {
+		v.listed[name] = nil
+	}
+	for _, record := range batch.Delete {
+		if records, exist := v.listed[record.CanonicalName]; exist {
+			v.listed[record.CanonicalName] = slices.DeleteFunc(records, func(r *core.Record) bool {
+				return r.Type == record.Type && r.Value == record.Value
+			})
+		}
+	}
+	for _, record := range batch.Append {
+		if records, exist := v.listed[record.CanonicalName]; exist {
+			r := *record
+			v.listed[record.CanonicalName] = append(records, &r)
+		}
+	}
+}
+
+// pending returns the batch without the appends the registry holds and the deletions of records it does not hold.
+// Writes are idempotent per registry, so retrying a write that failed in some registries does not duplicate records in the others.
+func (v *view) pending(batch *core.Batch) (*core.Batch, error) {
+	p := &core.Batch{DeleteAll: batch.DeleteAll}
+
+	for _, record := range batch.Delete {
+		held, err := v.holds(record, false)
+		if err != nil {
+			return nil, err
+		}
+		if held {
+			p.Delete = append(p.Delete, record)
+		}
+	}
+	for _, record := range batch.Append {
+		// Records deleted first by the batch are appended again.
+		deleted := slices.Contains(batch.DeleteAll, record.CanonicalName) || slices.ContainsFunc(batch.Delete, func(r *core.Record) bool {
+			return r.CanonicalName == record.CanonicalName && r.Type == record.Type && r.Value == record.Value
+		})
+		held, err := v.holds(record, true)
+		if err != nil {
+			return nil, err
+		}
+		if deleted || !held {
+			p.Append = append(p.Append, record)
+		}
+	}
+	return p, nil
+}
+
+// applyTo applies the pending part of the batch to the registry by the call.
+func (r *Registry) applyTo(registry core.Registry, batch *core.Batch, call func(p *core.Batch) error) error {
+	v := r.view(registry)
+	p, err := v.pending(batch)
+	if err != nil || len(p.DeleteAll)+len(p.Delete)+len(p.Append) == 0 {
+		return err
+	}
+	err = call(p)
+	v.apply(p, err)
+	return err
+}
+
+func (r *Registry) AppendRecord(record *core.Record) error {
+	return r.write(func(registry core.Registry) error {
+		return r.applyTo(registry, &core.Batch{Append: []*core.Record{record}}, func(*core.Batch) error {
+			return registry.AppendRecord(record)
+		})
+	})
+}
+
+func (r *Registry) DeleteRecord(record *core.Record) error {
+	return r.write(func(registry core.Registry) error {
+		return r.applyTo(registry, &core.Batch{Delete: []*core.Record{record}}, func(*core.Batch) error {
+			return registry.DeleteRecord(record)
+		})
+	})
+}
+
+func (r *Registry) DeleteAllRecordsWithDomain(domain string) error {
+	return r.write(func(registry core.Registry) error {
+		return r.applyTo(registry, &core.Batch{DeleteAll: []string{domain}}, func(*core.Batch) error {
+			return registry.DeleteAllRecordsWithDomain(domain)
+		})
+	})
+}
+
+// ApplyBatch applies the pending part of the batch to each registry, natively where supported.
+// The failure wraps core.ErrPartialBatch unless the primary has rejected a native batch, before any registry changed.
+func (r *Registry) ApplyBatch(batch *core.Batch) error {
+	primary, partial := true, false
+	err := r.write(func(registry core.Registry) error {
+		err := r.applyTo(registry, batch, func(p *core.Batch) error {
+			return core.ApplyBatch(registry, p)
+		})
+		if _, native := registry.(core.BatchRegistry); err != nil && (!primary || !native) {
+			partial = true
+		}
+		primary = false
+		return err
+	})
+	if err != nil && partial {
+		return fmt.Errorf("%w: %v", core.ErrPartialBatch, err)
+	}
+	return err
+}
+
+func (r *Registry) Close() error {
+	var errs []error
+	for _, registry := range r.Registries {
+		errs = append(errs, registry.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func Build(c *core.Context, config map[string]string) (core.Registry, error) {
+	var (
+		registries = config["registries"]
+		mode       = config["mode"]
+	)
+	if registries == "" {
+		return nil, fmt.Errorf("mirror: require [registries]")
+	}
+
+	switch mode {
+	case "":
+		mode = MODE_ALL
+	case MODE_ALL, MODE_PRIMARY:
+	default:
+		return nil, fmt.Errorf("mirror: unknown mode [%s]", mode)
+	}
+
+	r := &Registry{Mode: mode}
+
+	for _, name := range strings.Split(registries, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || slices.Contains(r.Names, name) {
+			continue
+		}
+
+		// Mirrors of mirrors are rejected, so the definitions cannot refer to each other in a loop.
+		registryDef, err := core.Query(c, &core.RegistryDef{}, "registry", name)
+		if err != nil {
+			_ = r.Close()
+			return nil, fmt.Errorf("mirror: registry [%s]: %v", name, err)
+		}
+		if registryDef.Builder == "mirror" {
+			_ = r.Close()
+			return nil, fmt.Errorf("mirror: registry [%s] is a mirror", name)
+		}
+
+		registry, err := core.BuildRegistry(c, name)
+		if err != nil {
+			_ = r.Close()
+			return nil, fmt.Errorf("mirror: %v", err)
+		}
+		r.Names = append(r.Names, name)
+		r.Registries = append(r.Registries, registry)
+	}
+	if len(r.Registries) == 0 {
+		return nil, fmt.Errorf("mirror: require [registries]")
+	}
+
+	return r, nil
+}
+
+func init() {
+	core.ContextRegistryBuilders["mirror"] = Build
+}
@@ -0,0 +1,161 @@
+// Copyright 2025 Jelly Terra <jellyterra@proton.me>
+// This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0
+// that can be found in the LICENSE file and https://mozilla.org/MPL/2.0/.
+
+package mirror
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/autodns/autodns.go/core"
+)
+
+// memRegistry holds records in memory, failing writes while fail is set.
+type memRegistry struct {
+	records []*core.Record
+	fail    bool
+	// cached are listed instead of the records if not nil, as registries listing once when built.
+	cached []*core.Record
+}
+
+func (m *memRegistry) ListRecords(domain string) (records []*core.Record, _ error) {
+	listed := m.records
+	if m.cached != nil {
+		listed = m.cached
+	}
+	for _, record := range listed {
+		if record.CanonicalName == domain {
+			r := *record
+			records = append(records, &r)
+		}
+	}
+	return records, nil
+}
+
+func (m *memRegistry) AppendRecord(record *core.Record) error {
+	if m.fail {
+		return errors.New("unavailable")
+	}
+	r := *record
+	m.records = append(m.records, &r)
+	return nil
+}
+
+func (m *memRegistry) DeleteRecord(record *core.Record) error {
+	if m.fail {
+		return errors.New("unavailable")
+	}
+	m.records = slices.DeleteFunc(m.records, func(r *core.Record) bool {
+		return r.CanonicalName == record.CanonicalName && r.Type == record.Type && r.Value == record.Value
+	})
+	return nil
+}
+
+func (m *memRegistry) DeleteAllRecordsWithDomain(domain string) error {
+	if m.fail {
+		return errors.New("unavailable")
+	}
+	m.records = slices.DeleteFunc(m.records, func(r *core.Record) bool { return r.CanonicalName == domain })
+	return nil
+}
+
+func (m *memRegistry) Close() error { return nil }
+
+func TestCachedList(t *testing.T) {
+	a := &core.Record{CanonicalName: "jellyterra.com", Type: "A", Value: "203.0.113.1", TTL: 300}
+
+	for _, tt := range []struct {
+		title string
+		write func(r *Registry) error
+	}{
+		{
+			title: "update",
+			write: func(r *Registry) error {
+				// As ExecuteAll runs an update of the same value.
+				err := r.DeleteAllRecordsWithDomain(a.CanonicalName)
+				if err != nil {
+					return err
+				}
+				return r.AppendRecord(a)
+			},
+		},
+		{
+			title: "delete and append",
+			write: func(r *Registry) error {
+				err := r.DeleteRecord(a)
+				if err != nil {
+					return err
+				}
+				return r.ApplyBatch(&core.Batch{Append: []*core.Record{a}})
+			},
+		},
+	} {
+		t.Run(tt.title, func(t *testing.T) {
+			primary := &memRegistry{records: []*core.Record{a}, cached: []*core.Record{a}}
+			secondary := &memRegistry{records: []*core.Record{a}, cached: []*core.Record{a}}
+			r := &Registry{Names: []string{"primary", "secondary"}, Registries: []core.Registry{primary, secondary}, Mode: MODE_ALL}
+
+			if err := tt.write(r); err != nil {
+				t.Fatal(err)
+			}
+			for i, m := range []*memRegistry{primary, secondary} {
+				if len(m.records) != 1 || *m.records[0] != *a {
+					t.Errorf("%s holds %v, want %v", r.Names[i], m.records, a)
+				}
+			}
+		})
+	}
+}
+
+func TestRetry(t *testing.T) {
+	a := &core.Record{CanonicalName: "jellyterra.com", Type: "A", Value: "203.0.113.1", TTL: 300}
+	b := &core.Record{CanonicalName: "jellyterra.com", Type: "A", Value: "203.0.113.1", TTL: 3600}
+
+	for _, tt := range []struct {
+		title   string
+		current []*core.Record
+		write   func(r *Registry) error
+		want    []*core.Record
+	}{
+		{
+			title: "append",
+			write: func(r *Registry) error { return r.AppendRecord(a) },
+			want:  []*core.Record{a},
+		},
+		{
+			title: "batch",
+			write: func(r *Registry) error { return r.ApplyBatch(&core.Batch{Append: []*core.Record{a}}) },
+			want:  []*core.Record{a},
+		},
+		{
+			title:   "TTL change",
+			current: []*core.Record{a},
+			write: func(r *Registry) error {
+				return r.ApplyBatch(&core.Batch{Delete: []*core.Record{a}, Append: []*core.Record{b}})
+			},
+			want: []*core.Record{b},
+		},
+	} {
+		t.Run(tt.title, func(t *testing.T) {
+			primary := &memRegistry{records: slices.Clone(tt.current)}
+			secondary := &memRegistry{records: slices.Clone(tt.current), fail: true}
+			r := &Registry{Names: []string{"primary", "secondary"}, Registries: []core.Registry{primary, secondary}, Mode: MODE_ALL}
+
+			if err := tt.write(r); err == nil {
+				t.Fatal("write succeeded with the secondary failing")
+			}
+			secondary.fail = false
+			if err := tt.write(r); err != nil {
+				t.Fatal(err)
+			}
+
+			for i, m := range []*memRegistry{primary, secondary} {
+				if !slices.EqualFunc(m.records, tt.want, func(a, b *core.Record) bool { return *a == *b }) {
+					t.Errorf("%s holds %v, want %v", r.Names[i], m.records, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,324 @@
+// Copyright 2025 Jelly Terra <jellyterra@proton.me>
+// This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0
+// that can be found in the LICENSE file and https://mozilla.org/MPL/2.0/.
+
+// Package zonefile reads and writes records in the RFC 1035 master file format.
+package zonefile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/autodns/autodns.go/core"
+)
+
+// NameTypes are the types whose last field of value is a domain name, absolute in zone files with a trailing dot.
+var NameTypes = []string{"CNAME", "NS", "PTR", "DNAME", "ALIAS", "MX", "SRV"}
+
+// mapName applies fn to the domain name in the value of the name type.
+func mapName(value string, fn func(name string) string) string {
+	i := strings.LastIndexByte(value, ' ') + 1
+	if value[i:] == "." {
+		// The root, as in null MX.
+		return value
+	}
+	return value[:i] + fn(value[i:])
+}
+
+var classes = []string{"IN", "CH", "HS", "CS"}
+
+func quote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+// MaxStringLength is the maximum length of a character-string in bytes.
+const MaxStringLength = 255
+
+// quoteStrings quotes the value as character-strings of at most MaxStringLength bytes, split between runes.
+func quoteStrings(value string) string {
+	var quoted []string
+	for len(value) > MaxStringLength {
+		i := MaxStringLength
+		for i > 0 && !utf8.RuneStart(value[i]) {
+			i--
+		}
+		quoted = append(quoted, quote(value[:i]))
+		value = value[i:]
+	}
+	return strings.Join(append(quoted, quote(value)), " ")
+}
+
+// relative returns the owner name relative to the origin where possible.
+func relative(name string, origin string) string {
+	switch {
+	case origin == "":
+		return name + "."
+	case name == origin:
+		return "@"
+	case strings.HasSuffix(name, "."+origin):
+		return strings.TrimSuffix(name, "."+origin)
+	default:
+		return name + "."
+	}
+}
+
+// Write writes the records in the zone file format, with owner names relative to the origin if not empty.
+func Write(w io.Writer, origin string, records []*core.Record) error {
+	bw := bufio.NewWriter(w)
+
+	if origin != "" {
+		_, _ = fmt.Fprintf(bw, "$ORIGIN %s.\n", origin)
+	}
+
+	for _, record := range records {
+		value := record.Value
+		switch {
+		case record.Type == "TXT" || record.Type == "SPF":
+			value = quoteStrings(value)
+		case slices.Contains(NameTypes, record.Type) && value != "":
+			value = mapName(value, func(name string) string {
+				return strings.TrimSuffix(name, ".") + "."
+			})
+		}
+		_, _ = fmt.Fprintf(bw, "%s\t%d\tIN\t%s\t%s\n", relative(record.CanonicalName, origin), record.TTL, record.Type, value)
+	}
+
+	return bw.Flush()
+}
+
+type token struct {
+	s      string
+	quoted bool
+}
+
+// lexer splits the input into entries of tokens, joining lines in parentheses and dropping comments.
+type lexer struct {
+	r    *bufio.Reader
+	line int
+}
+
+// next returns the tokens of the next entry, and whether it starts with blank to inherit the previous owner.
+func (l *lexer) next() (tokens []token, blank bool, line int, err error) {
+	depth := 0
+	for {
+		text, rErr := l.r.ReadString('\n')
+		if text == "" && rErr != nil {
+			if depth != 0 {
+				return nil, false, l.line, fmt.Errorf("line %d: unclosed parenthesis", l.line)
+			}
+			return nil, false, l.line, rErr
+		}
+		l.line++
+		if len(tokens) == 0 && depth == 0 {
+			blank = text != "" && (text[0] == ' ' || text[0] == '\t')
+			line = l.line
+		}
+
+		runes := []rune(strings.TrimRight(text, "\r\n"))
+		for i := 0; i < len(runes); i++ {
+			switch c := runes[i]; {
+			case c == ';':
+				i = len(runes)
+			case c == '(':
+				depth++
+			case c == ')':
+				depth--
+				if depth < 0 {
+					return nil, false, l.line, fmt.Errorf("line %d: unbalanced parenthesis", l.line)
+				}
+			case unicode.IsSpace(c):
+			case c == '"':
+				var b strings.Builder
+				closed := false
+				for i++; i < len(runes); i++ {
+					if runes[i] == '\\' && i+1 < len(runes) {
+						i++
+						b.WriteRune(runes[i])
+						continue
+					}
+					if runes[i] == '"' {
+						closed = true
+						break
+					}
+					b.WriteRune(runes[i])
+				}
+				if !closed {
+					return nil, false, l.line, fmt.Errorf("line %d: unclosed quote", l.line)
+				}
+				tokens = append(tokens, token{s: b.String(), quoted: true})
+			default:
+				start := i
+				for i < len(runes) && !unicode.IsSpace(runes[i]) && !strings.ContainsRune(`;()"`, runes[i]) {
+					i++
+				}
+				tokens = append(tokens, token{s: string(runes[start:i])})
+				i--
+			}
+		}
+
+		if depth == 0 && len(tokens) != 0 {
+			return tokens, blank, line, nil
+		}
+		if rErr != nil {
+			return nil, false, l.line, rErr
+		}
+	}
+}
+
+// ParseTTL parses the TTL in seconds, or with units s, m, h, d and w.
+func ParseTTL(s string) (int, bool) {
+	units := map[byte]int{'s': 1, 'm': 60, 'h': 3600, 'd': 86400, 'w': 604800}
+
+	total, n, digits := 0, 0, false
+	for i := 0; i < len(s); i++ {
+		c := s[i] | 0x20
+		switch {
+		case s[i] >= '0' && s[i] <= '9':
+			n = n*10 + int(s[i]-'0')
+			digits = true
+		case units[c] != 0 && digits:
+			total += n * units[c]
+			n, digits = 0, false
+		default:
+			return 0, false
+		}
+	}
+	if s == "" {
+		return 0, false
+	}
+	return total + n, true
+}
+
+// absolute resolves the name against the origin, without trailing dot.
+func absolute(name string, origin string) string {
+	switch {
+	case name == "@":
+		return origin
+	case strings.HasSuffix(name, "."):
+		return strings.TrimSuffix(name, ".")
+	case origin == "":
+		return name
+	default:
+		return name + "." + origin
+	}
+}
+
+// Parse reads records from the zone file. SOA records are skipped, as registries manage them.
+// The apex is the owner of SOA, or the origin given or set by the first $ORIGIN.
+func Parse(r io.Reader, origin string) (records []*core.Record, apex string, _ error) {
+	origin = strings.TrimSuffix(origin, ".")
+	apex = origin
+	soa := false
+
+	l := &lexer{r: bufio.NewReader(r)}
+	// Without $TTL, records without TTL take the TTL of the previous record, as in RFC 1035.
+	var (
+		defaultTTL = -1
+		lastTTL    = 3600
+		owner      string
+	)
+
+	for {
+		tokens, blank, line, err := l.next()
+		if err == io.EOF {
+			return records, apex, nil
+		}
+		if err != nil {
+			return nil, "", err
+		}
+
+		if !tokens[0].quoted && strings.HasPrefix(tokens[0].s, "$") {
+			if len(tokens) < 2 {
+				return nil, "", fmt.Errorf("line %d: %s requires an argument", line, tokens[0].s)
+			}
+			switch strings.ToUpper(tokens[0].s) {
+			case "$ORIGIN":
+				origin = absolute(tokens[1].s, origin)
+				if apex == "" {
+					apex = origin
+				}
+			case "$TTL":
+				ttl, ok := ParseTTL(tokens[1].s)
+				if !ok {
+					return nil, "", fmt.Errorf("line %d: invalid TTL %s", line, tokens[1].s)
+				}
+				defaultTTL = ttl
+			default:
+				return nil, "", fmt.Errorf("line %d: unsupported directive %s", line, tokens[0].s)
+			}
+			continue
+		}
+
+		if !blank {
+			owner = absolute(tokens[0].s, origin)
+			tokens = tokens[1:]
+		} else if owner == "" {
+			return nil, "", fmt.Errorf("line %d: no owner name", line)
+		}
+
+		ttl := -1
+		for len(tokens) != 0 && !tokens[0].quoted {
+			if slices.Contains(classes, strings.ToUpper(tokens[0].s)) {
+				tokens = tokens[1:]
+				continue
+			}
+			if t, ok := ParseTTL(tokens[0].s); ok && ttl == -1 {
+				ttl = t
+				tokens = tokens[1:]
+				continue
+			}
+			break
+		}
+		if len(tokens) < 2 {
+			return nil, "", fmt.Errorf("line %d: requires type and value", line)
+		}
+
+		switch {
+		case ttl != -1:
+			lastTTL = ttl
+		case defaultTTL != -1:
+			ttl = defaultTTL
+		default:
+			ttl = lastTTL
+		}
+
+		typ := strings.ToUpper(tokens[0].s)
+		if typ == "SOA" {
+			if !soa {
+				soa, apex = true, owner
+			}
+			continue
+		}
+
+		var value string
+		switch {
+		case typ == "TXT" || typ == "SPF":
+			var b strings.Builder
+			for _, t := range tokens[1:] {
+				b.WriteString(t.s)
+			}
+			value = b.String()
+		default:
+			values := make([]string, len(tokens)-1)
+			for i, t := range tokens[1:] {
+				values[i] = t.s
+			}
+			value = strings.Join(values, " ")
+
+			if slices.Contains(NameTypes, typ) {
+				value = mapName(value, func(name string) string {
+					return absolute(name, origin)
+				})
+			}
+		}
+
+		records = append(records, &core.Record{Type: typ, CanonicalName: owner, Value: value, TTL: ttl})
+	}
+}
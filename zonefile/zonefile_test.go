@@ -0,0 +1,170 @@
+// Copyright 2025 Jelly Terra <jellyterra@proton.me>
+// This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0
+// that can be found in the LICENSE file and https://mozilla.org/MPL/2.0/.
+
+package zonefile
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/autodns/autodns.go/core"
+)
+
+func formatRecords(records []*core.Record) (lines []string) {
+	for _, record := range records {
+		lines = append(lines, fmt.Sprintf("%s %d %s %s", record.CanonicalName, record.TTL, record.Type, record.Value))
+	}
+	return lines
+}
+
+func TestParse(t *testing.T) {
+	for _, tt := range []struct {
+		title  string
+		origin string
+		zone   string
+		want   []string
+		apex   string
+	}{
+		{
+			title: "origin and TTL directives",
+			zone: "$ORIGIN jellyterra.com.\n" +
+				"$TTL 1h\n" +
+				"@ IN A 203.0.113.1\n" +
+				"www 300 IN CNAME @\n" +
+				"$ORIGIN hosts.jellyterra.com.\n" +
+				"edge-a A 203.0.113.2\n",
+			want: []string{
+				"jellyterra.com 3600 A 203.0.113.1",
+				"www.jellyterra.com 300 CNAME jellyterra.com",
+				"edge-a.hosts.jellyterra.com 3600 A 203.0.113.2",
+			},
+			apex: "jellyterra.com",
+		},
+		{
+			title:  "previous TTL and owner without $TTL",
+			origin: "jellyterra.com.",
+			zone: "@ 600 IN MX 10 mx\n" +
+				"\tIN MX 20 mx.example.net.\n" +
+				"mx A 203.0.113.3 ; comment\n",
+			want: []string{
+				"jellyterra.com 600 MX 10 mx.jellyterra.com",
+				"jellyterra.com 600 MX 20 mx.example.net",
+				"mx.jellyterra.com 600 A 203.0.113.3",
+			},
+			apex: "jellyterra.com",
+		},
+		{
+			title:  "parentheses",
+			origin: "jellyterra.com",
+			zone: "@ 3600 IN SOA ns1 hostmaster (\n" +
+				"\t1 ; serial\n" +
+				"\t7200 900 1209600 300 )\n" +
+				"_sip._tcp 300 IN SRV ( 10 60\n" +
+				"\t5060 sip )\n",
+			want: []string{"_sip._tcp.jellyterra.com 300 SRV 10 60 5060 sip.jellyterra.com"},
+			apex: "jellyterra.com",
+		},
+		{
+			title:  "quoting",
+			origin: "jellyterra.com",
+			zone: `txt 300 IN TXT "v=spf1 -all ; (not a comment)"` + "\n" +
+				`txt 300 IN TXT "a \"quoted\" \\ value" "joined"` + "\n",
+			want: []string{
+				`txt.jellyterra.com 300 TXT v=spf1 -all ; (not a comment)`,
+				`txt.jellyterra.com 300 TXT a "quoted" \ valuejoined`,
+			},
+			apex: "jellyterra.com",
+		},
+		{
+			title: "apex of SOA",
+			zone: "$ORIGIN com.\n" +
+				"jellyterra 3600 IN SOA ns1.jellyterra hostmaster.jellyterra 1 7200 900 1209600 300\n" +
+				"jellyterra 3600 IN NS ns1.jellyterra\n",
+			want: []string{"jellyterra.com 3600 NS ns1.jellyterra.com"},
+			apex: "jellyterra.com",
+		},
+	} {
+		t.Run(tt.title, func(t *testing.T) {
+			records, apex, err := Parse(strings.NewReader(tt.zone), tt.origin)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := formatRecords(records); !slices.Equal(got, tt.want) {
+				t.Errorf("Parse() =\n%q\nwant\n%q", got, tt.want)
+			}
+			if apex != tt.apex {
+				t.Errorf("apex = %q, want %q", apex, tt.apex)
+			}
+		})
+	}
+}
+
+func TestParseError(t *testing.T) {
+	for _, zone := range []string{
+		"@ 300 IN TXT \"unclosed\n",
+		"@ 300 IN SRV ( 10 60 5060 sip\n",
+		"@ 300 IN A 203.0.113.1 )\n",
+		"\t300 IN A 203.0.113.1\n",
+		"$INCLUDE other.zone\n",
+	} {
+		_, _, err := Parse(strings.NewReader(zone), "jellyterra.com")
+		if err == nil {
+			t.Errorf("Parse(%q) succeeded", zone)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	long := strings.Repeat("v=DKIM1; k=rsa; p=", 10) + strings.Repeat("ü", 200)
+	records := []*core.Record{
+		{CanonicalName: "jellyterra.com", Type: "A", Value: "203.0.113.1", TTL: 300},
+		{CanonicalName: "jellyterra.com", Type: "MX", Value: "10 mx.jellyterra.com", TTL: 3600},
+		{CanonicalName: "jellyterra.com", Type: "MX", Value: "0 .", TTL: 3600},
+		{CanonicalName: "www.jellyterra.com", Type: "CNAME", Value: "jellyterra.com", TTL: 300},
+		{CanonicalName: "txt.jellyterra.com", Type: "TXT", Value: `a "quoted" \ value; (kept)`, TTL: 300},
+		{CanonicalName: "dkim._domainkey.jellyterra.com", Type: "TXT", Value: long, TTL: 300},
+		{CanonicalName: "jellyterra.net", Type: "A", Value: "203.0.113.2", TTL: 300},
+	}
+
+	for _, origin := range []string{"jellyterra.com", ""} {
+		t.Run("origin "+origin, func(t *testing.T) {
+			var b bytes.Buffer
+			err := Write(&b, origin, records)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Character-strings are at most 255 bytes.
+			for _, line := range strings.Split(b.String(), "\n") {
+				if !strings.Contains(line, "dkim") {
+					continue
+				}
+				tokens, _, _, err := (&lexer{r: bufio.NewReader(strings.NewReader(line))}).next()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if n := len(tokens) - 4; n != 3 {
+					t.Errorf("long TXT written in %d strings", n)
+				}
+				for _, token := range tokens[4:] {
+					if len(token.s) > MaxStringLength {
+						t.Errorf("string of %d bytes", len(token.s))
+					}
+				}
+			}
+
+			parsed, _, err := Parse(&b, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := formatRecords(parsed), formatRecords(records); !slices.Equal(got, want) {
+				t.Errorf("round trip =\n%q\nwant\n%q", got, want)
+			}
+		})
+	}
+}